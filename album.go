@@ -0,0 +1,53 @@
+package packer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/paveldroo/go-ogg-packer/loudness"
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/tags"
+)
+
+// WriteAlbumGain sets the R128_ALBUM_GAIN comment of the given Ogg Opus
+// files to the gain that brings the album measured by a to the reference
+// loudness. The gain is stored relative to the output gain of each file,
+// as RFC 7845 requires, so files normalized with WithR128OutputGain get
+// the right value too. The files are edited in place where the reserved
+// header space allows it.
+func WriteAlbumGain(a *loudness.Album, files ...string) error {
+	albumGain := int(loudness.Q78(a.Gain()))
+
+	for _, name := range files {
+		outputGain, err := readOutputGain(name)
+		if err != nil {
+			return err
+		}
+
+		err = tags.RewriteFile(name, func(t *ogg.Tags) {
+			t.Set("R128_ALBUM_GAIN", strconv.Itoa(albumGain-int(outputGain)))
+		})
+		if err != nil {
+			return fmt.Errorf("write album gain to %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func readOutputGain(name string) (int16, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, fmt.Errorf("open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	r, err := oggopus.NewReader(f)
+	if err != nil {
+		return 0, fmt.Errorf("read header of %s: %w", name, err)
+	}
+
+	return r.Head().OutputGain, nil
+}
@@ -0,0 +1,69 @@
+package packer_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/loudness"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+func TestWriteAlbumGain(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+	quiet := make([]int16, len(sourcePCMData))
+	for i, s := range sourcePCMData {
+		quiet[i] = s / 4
+	}
+
+	var album loudness.Album
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "1.opus"), filepath.Join(dir, "2.opus")}
+	for i, pcm := range [][]int16{sourcePCMData, quiet} {
+		f, err := os.Create(files[i])
+		if err != nil {
+			t.Fatalf("create file: %s", err.Error())
+		}
+		opts := []packer.Option{packer.WithWriter(f), packer.WithAlbum(&album)}
+		if i == 1 {
+			opts = append(opts, packer.WithR128OutputGain())
+		}
+		p, err := packer.New(opts...)
+		if err != nil {
+			t.Fatalf("create new packer: %s", err.Error())
+		}
+		if err := p.SendPCMChunk(pcm); err != nil {
+			t.Fatalf("send PCM chunk: %s", err.Error())
+		}
+		if err := p.Close(); err != nil {
+			t.Fatalf("close packer: %s", err.Error())
+		}
+		f.Close()
+	}
+
+	if err := packer.WriteAlbumGain(&album, files...); err != nil {
+		t.Fatalf("write album gain: %s", err.Error())
+	}
+
+	albumGain := int(loudness.Q78(album.Gain()))
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("open file: %s", err.Error())
+		}
+		info, err := oggopus.Probe(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("probe: %s", err.Error())
+		}
+
+		link := info.Links[0]
+		want := strconv.Itoa(albumGain - int(link.Head.OutputGain))
+		if got := link.Tags.Get("R128_ALBUM_GAIN"); len(got) != 1 || got[0] != want {
+			t.Fatalf("album gain of %s should be equal %s, current %v", filepath.Base(name), want, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package packer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	extogg "mccoy.space/g/ogg"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/opus"
+)
+
+func TestPackerStartNewChain(t *testing.T) {
+	sourcePCMData := pcmData(t, "testdata/48k_1ch.pcm")
+
+	tests := []struct {
+		name     string
+		seekable bool
+	}{
+		{
+			name: "buffered",
+		},
+		{
+			name:     "seekable writer",
+			seekable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &seekBuffer{}
+			opts := []packer.Option{packer.WithTag("TITLE", "intro")}
+			if tt.seekable {
+				opts = append(opts, packer.WithWriter(ws))
+			}
+
+			p, err := packer.New(opts...)
+			if err != nil {
+				t.Fatalf("create new packer: %s", err.Error())
+			}
+			if err := p.SendPCMChunk(sourcePCMData[:48000]); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+
+			stereo := opus.Config{SampleRate: 24000, NumChannels: 2, FrameSize: 20 * time.Millisecond}
+			if err := p.StartNewChain(packer.WithConfig(stereo), packer.WithTag("TITLE", "news")); err != nil {
+				t.Fatalf("start new chain: %s", err.Error())
+			}
+			if err := p.SendPCMChunk(sourcePCMData[:48000]); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+
+			var audioData []byte
+			if tt.seekable {
+				err = p.Close()
+				audioData = ws.data.Bytes()
+			} else {
+				audioData, err = p.GetResult()
+			}
+			if err != nil {
+				t.Fatalf("finish packer: %s", err.Error())
+			}
+
+			var links [][]extogg.Page
+			for _, page := range oggPages(t, audioData) {
+				if page.Type&extogg.BOS != 0 {
+					links = append(links, nil)
+				}
+				links[len(links)-1] = append(links[len(links)-1], page)
+			}
+
+			if len(links) != 2 {
+				t.Fatalf("chain links count should be equal 2, current %d", len(links))
+			}
+
+			wantTitles := []string{"TITLE=intro", "TITLE=news"}
+			wantChannels := []byte{1, 2}
+			for i, link := range links {
+				if link[len(link)-1].Type&extogg.EOS == 0 {
+					t.Fatalf("link %d should end with an EOS page", i)
+				}
+				if link[0].Packets[0][9] != wantChannels[i] {
+					t.Fatalf("link %d channels should be equal %d, current %d", i, wantChannels[i], link[0].Packets[0][9])
+				}
+				if tags := string(link[1].Packets[0]); !strings.Contains(tags, wantTitles[i]) {
+					t.Fatalf("link %d tags should contain %s, current %q", i, wantTitles[i], tags)
+				}
+				if tt.seekable && !strings.Contains(string(link[1].Packets[0]), "DURATION=00:00:01.") {
+					t.Fatalf("link %d tags should contain its duration", i)
+				}
+			}
+
+			if links[0][0].Serial == links[1][0].Serial {
+				t.Fatal("chain links should have distinct serial numbers")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+func runConcat(args []string) error {
+	fs := flag.NewFlagSet("concat", flag.ExitOnError)
+	output := fs.String("o", "", "output file")
+	chain := fs.Bool("chain", false, "chain inputs with incompatible headers instead of failing")
+	reencode := fs.Bool("reencode", false, "re-encode the packets around joins to keep one logical stream")
+	fs.Parse(args)
+
+	if *output == "" || fs.NArg() == 0 {
+		return errors.New("need an output file and at least one input file")
+	}
+
+	var inputs []io.Reader
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer f.Close()
+		inputs = append(inputs, f)
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+
+	var opts []oggopus.ConcatOption
+	if *chain {
+		opts = append(opts, oggopus.ChainIncompatible())
+	}
+	if *reencode {
+		opts = append(opts, oggopus.ReencodeJoins())
+	}
+	if err := oggopus.Concat(out, inputs, opts...); err != nil {
+		out.Close()
+		os.Remove(*output)
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+func runCut(args []string) error {
+	fs := flag.NewFlagSet("cut", flag.ExitOnError)
+	start := fs.Duration("start", 0, "start of the range")
+	end := fs.Duration("end", 0, "end of the range, 0 for the end of the input")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		return errors.New("need an input and an output file")
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+
+	if err := oggopus.Cut(in, out, *start, *end); err != nil {
+		out.Close()
+		os.Remove(fs.Arg(1))
+		return err
+	}
+
+	return out.Close()
+}
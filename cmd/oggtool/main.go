@@ -0,0 +1,65 @@
+// Command oggtool inspects and edits Ogg Opus files without re-encoding
+// them.
+//
+// Usage:
+//
+//	oggtool <command> [flags] <args>
+//
+// Commands:
+//
+//	cut       copy a time range of a file into a new file
+//	concat    join files into one
+//	probe     print duration, headers and comments
+//	rtp       record an opus RTP stream from a pcap capture
+//	tags      print or edit comments
+//	waveform  compute min/max peaks for drawing
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"cut", "cut [-start d] [-end d] <in.ogg> <out.ogg>", runCut},
+	{"concat", "concat [-chain] -o <out.ogg> <in.ogg>...", runConcat},
+	{"probe", "probe [-json] <in.ogg>...", runProbe},
+	{"rtp", "rtp [-port n] [-pt n] [-channels n] [-loss gap|plc|fec] -o <out.ogg> <in.pcap>", runRTP},
+	{"tags", "tags [-set K=V]... [-add K=V]... [-delete K]... [-o out.ogg] <in.ogg>", runTags},
+	{"waveform", "waveform [-pps n] [-o out.json|out.dat] <in.ogg>", runWaveform},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("oggtool: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				log.Fatalf("%s: %s", c.name, err.Error())
+			}
+			return
+		}
+	}
+
+	usage()
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\toggtool %s\n", c.usage)
+	}
+	os.Exit(2)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+func runProbe(args []string) error {
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print one JSON object per file")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("need at least one input file")
+	}
+
+	for _, name := range fs.Args() {
+		info, err := probeFile(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if *asJSON {
+			b, err := json.Marshal(struct {
+				File string `json:"file"`
+				oggopus.Info
+			}{name, info})
+			if err != nil {
+				return fmt.Errorf("encode info: %w", err)
+			}
+			fmt.Println(string(b))
+			continue
+		}
+
+		fmt.Printf("%s: %s, %d bytes\n", name, info.Duration, info.Size)
+		for i, l := range info.Links {
+			fmt.Printf("  link %d: serial %d, %d ch, %d Hz, %s, %d kbit/s, %d pages\n",
+				i, l.Serial, l.Head.Channels, l.Head.InputSampleRate, l.Duration, l.Bitrate/1000, l.Pages)
+			printComments(l.Tags, "    ")
+		}
+	}
+
+	return nil
+}
+
+// printComments prints the comments of t, summarizing pictures instead of
+// printing their encoded data.
+func printComments(t ogg.Tags, indent string) {
+	for _, c := range t.Comments {
+		if k, _, _ := strings.Cut(c, "="); !strings.EqualFold(k, ogg.PictureKey) {
+			fmt.Printf("%s%s\n", indent, c)
+		}
+	}
+
+	pictures, err := t.Pictures()
+	if err != nil {
+		fmt.Printf("%spicture: %s\n", indent, err.Error())
+		return
+	}
+	for _, p := range pictures {
+		fmt.Printf("%spicture: type %d, %s, %dx%d, %d bytes, %q\n",
+			indent, p.Type, p.MIME, p.Width, p.Height, len(p.Data), p.Description)
+	}
+}
+
+func probeFile(name string) (oggopus.Info, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return oggopus.Info{}, err
+	}
+	defer f.Close()
+
+	return oggopus.Probe(f)
+}
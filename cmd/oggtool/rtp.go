@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/rtp"
+)
+
+var losses = map[string]rtp.Loss{"gap": rtp.Gap, "plc": rtp.PLC, "fec": rtp.FEC}
+
+func runRTP(args []string) error {
+	fs := flag.NewFlagSet("rtp", flag.ExitOnError)
+	port := fs.Uint("port", 0, "UDP destination port of the stream, any if 0")
+	pt := fs.Uint("pt", 0, "RTP payload type of opus, any if 0")
+	ssrc := fs.String("ssrc", "", "SSRC of the stream, decimal or 0x hex; the first one seen if empty")
+	channels := fs.Int("channels", 1, "channel count of the output")
+	loss := fs.String("loss", "gap", "what fills lost packets: gap, plc or fec")
+	output := fs.String("o", "", "output file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *output == "" {
+		return errors.New("need an output and one input file")
+	}
+	mode, ok := losses[*loss]
+	if !ok {
+		return fmt.Errorf("unknown loss mode %q", *loss)
+	}
+	cfg := rtp.Config{PayloadType: uint8(*pt), Channels: *channels, Loss: mode}
+	if *ssrc != "" {
+		v, err := strconv.ParseUint(*ssrc, 0, 32)
+		if err != nil {
+			return fmt.Errorf("parse ssrc: %w", err)
+		}
+		cfg.SSRC = uint32(v)
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
+
+	pr, err := rtp.NewPcapReader(in)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+
+	r, err := record(pr, out, cfg, uint16(*port))
+	if err != nil {
+		out.Close()
+		os.Remove(*output)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	s := r.Stats()
+	log.Printf("%v recorded, %d packets, %d lost, %d late, %d duplicate, %d ignored, %d SSRC changes",
+		r.Duration(), s.Packets, s.Lost, s.Late, s.Duplicate, s.Ignored, s.SSRCChanges)
+
+	return nil
+}
+
+// record writes the RTP stream in pr to w. Unless cfg selects an SSRC, the
+// recorder is created with that of the first packet, so that other streams
+// in the capture are ignored. Packets are held by their capture times.
+func record(pr *rtp.PcapReader, w io.Writer, cfg rtp.Config, port uint16) (*rtp.Recorder, error) {
+	var r *rtp.Recorder
+	var now time.Time
+	cfg.Now = func() time.Time { return now }
+	for {
+		u, err := pr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		now = u.Time
+		if r != nil {
+			if err := r.Advance(now); err != nil {
+				return nil, err
+			}
+		}
+		if port != 0 && u.Dst.Port() != port {
+			continue
+		}
+		p, err := rtp.Parse(u.Payload)
+		if err != nil {
+			// Other UDP traffic, or RTCP sharing the port.
+			continue
+		}
+		if p.PayloadType >= 72 && p.PayloadType <= 76 {
+			// RTCP multiplexed with RTP (RFC 5761).
+			continue
+		}
+		if r == nil {
+			if cfg.PayloadType != 0 && p.PayloadType != cfg.PayloadType {
+				continue
+			}
+			if cfg.SSRC == 0 {
+				cfg.SSRC = p.SSRC
+			}
+			if r, err = rtp.NewRecorder(w, cfg); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.WriteRTP(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if r == nil {
+		var err error
+		if r, err = rtp.NewRecorder(w, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return r, r.Close()
+}
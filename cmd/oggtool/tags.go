@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/tags"
+)
+
+// listFlag collects the values of a flag given several times.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func runTags(args []string) error {
+	var set, add, del listFlag
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	fs.Var(&set, "set", "replace comments with `KEY=value`, may be repeated")
+	fs.Var(&add, "add", "add a `KEY=value` comment, may be repeated")
+	fs.Var(&del, "delete", "delete all comments with `KEY`, may be repeated")
+	output := fs.String("o", "", "write the result to this file instead of editing in place")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("need exactly one input file")
+	}
+	name := fs.Arg(0)
+
+	if len(set)+len(add)+len(del) == 0 {
+		return printTags(name)
+	}
+
+	for _, kv := range append(set, add...) {
+		if !strings.Contains(kv, "=") {
+			return fmt.Errorf("comment %q is not in KEY=value form", kv)
+		}
+	}
+	edit := func(t *ogg.Tags) {
+		for _, k := range del {
+			t.Delete(k)
+		}
+		for _, kv := range set {
+			k, v, _ := strings.Cut(kv, "=")
+			t.Set(k, v)
+		}
+		for _, kv := range add {
+			k, v, _ := strings.Cut(kv, "=")
+			t.Add(k, v)
+		}
+	}
+
+	if *output == "" {
+		return tags.RewriteFile(name, edit)
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+	if err := tags.Rewrite(in, out, edit); err != nil {
+		out.Close()
+		os.Remove(*output)
+		return err
+	}
+
+	return out.Close()
+}
+
+func printTags(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+
+	r, err := oggopus.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	t := r.Tags()
+	fmt.Printf("vendor: %s\n", t.Vendor)
+	printComments(t, "")
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paveldroo/go-ogg-packer/waveform"
+)
+
+func runWaveform(args []string) error {
+	fs := flag.NewFlagSet("waveform", flag.ExitOnError)
+	pixel := fs.Int("pps", 480, "samples per pixel at 48 kHz")
+	output := fs.String("o", "", "output file, .dat for the binary format, standard output as JSON if empty")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("need one input file")
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("open input: %w", err)
+	}
+	defer in.Close()
+
+	w, err := waveform.FromOpus(in, *pixel)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return json.NewEncoder(os.Stdout).Encode(w)
+	}
+
+	out, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("create output: %w", err)
+	}
+	if filepath.Ext(*output) == ".dat" {
+		err = w.WriteDat(out)
+	} else {
+		err = json.NewEncoder(out).Encode(w)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(*output)
+		return err
+	}
+
+	return out.Close()
+}
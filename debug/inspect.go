@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -11,6 +12,7 @@ import (
 	extogg "mccoy.space/g/ogg"
 
 	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/filter"
 	"github.com/paveldroo/go-ogg-packer/opus"
 )
 
@@ -30,10 +32,22 @@ func readPCM(fn string) []int16 {
 }
 
 func main() {
+	denoise := flag.Bool("denoise", false, "run the noise reducer before encoding")
+	gate := flag.Bool("gate", false, "run the noise gate before encoding")
+	flag.Parse()
+
 	src := readPCM("testdata/48k_1ch.pcm")
 	ref := readPCM("testdata/want/48k_1ch.pcm")
 
-	p, err := packer.New()
+	var filters []filter.Filter
+	if *denoise {
+		filters = append(filters, filter.NoiseReducer(opus.SampleRate, opus.NumChannels, filter.NoiseConfig{}))
+	}
+	if *gate {
+		filters = append(filters, filter.Gate(opus.SampleRate, opus.NumChannels, filter.GateConfig{}))
+	}
+
+	p, err := packer.New(packer.WithFilters(filters...))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -60,11 +74,16 @@ func main() {
 	od, _ := extopus.NewDecoder(opus.SampleRate, opus.NumChannels)
 	pcmBuf := make([]int16, opus.FrameSize*opus.SampleRate*opus.NumChannels/1000)
 	var got []int16
+	preSkip := 0
 	for {
 		page, err := og.Decode()
 		if err != nil {
 			break
 		}
+		if page.Type&extogg.BOS != 0 {
+			preSkip = int(binary.LittleEndian.Uint16(page.Packets[0][10:12]))
+			continue
+		}
 		for _, packet := range page.Packets {
 			n, err := od.Decode(packet, pcmBuf)
 			if err != nil {
@@ -74,8 +93,16 @@ func main() {
 		}
 	}
 
+	// Filters with latency are compensated by pre-skip.
+	got = got[min(preSkip, len(got)):]
+
 	fmt.Printf("ref len=%d got len=%d\n", len(ref), len(got))
 	d := 0.0
+	if len(filters) > 0 {
+		// Filtered output is compared with the source instead.
+		ref = src[:min(len(src), len(got))]
+		got = got[:len(ref)]
+	}
 	if len(ref) == len(got) {
 		for i := range ref {
 			delta := float64(int(ref[i]) - int(got[i]))
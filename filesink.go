@@ -0,0 +1,125 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileSink is an output for WithWriter that writes pages straight through
+// to a file, so that the file on disk is always a valid Ogg prefix even if
+// the process dies in the middle of a recording.
+//
+// Data is flushed to stable storage with fsync at most once per sync
+// interval. A zero interval syncs after every write, a negative one only
+// on Close. FileSink implements io.WriterAt, so a Packer streaming into it
+// patches the header pages with the final duration on Close.
+type FileSink struct {
+	f            *os.File
+	syncInterval time.Duration
+	lastSync     time.Time
+	dirty        bool
+	size         int64
+}
+
+// NewFileSink creates or truncates the file at path.
+func NewFileSink(path string, syncInterval time.Duration) (*FileSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create file: %w", err)
+	}
+
+	return newFileSink(f, syncInterval), nil
+}
+
+func newFileSink(f *os.File, syncInterval time.Duration) *FileSink {
+	return &FileSink{
+		f:            f,
+		syncInterval: syncInterval,
+		lastSync:     time.Now(),
+	}
+}
+
+// createNew creates a file at path that did not exist before. If path is
+// taken, a counter is added before the extension, so "rec.ogg" becomes
+// "rec-1.ogg", "rec-2.ogg" and so on. It returns the file and its name.
+func createNew(path string) (*os.File, string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	name := path
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if err == nil {
+			return f, name, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, "", fmt.Errorf("create file: %w", err)
+		}
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+func (s *FileSink) Write(b []byte) (int, error) {
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("write file: %w", err)
+	}
+	s.dirty = true
+
+	if s.syncInterval >= 0 && time.Since(s.lastSync) >= s.syncInterval {
+		if err := s.Sync(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (s *FileSink) WriteAt(b []byte, off int64) (int, error) {
+	n, err := s.f.WriteAt(b, off)
+	s.size = max(s.size, off+int64(n))
+	if err != nil {
+		return n, fmt.Errorf("write file at %d: %w", off, err)
+	}
+	s.dirty = true
+
+	return n, nil
+}
+
+// Size returns the number of bytes written to the file.
+func (s *FileSink) Size() int64 {
+	return s.size
+}
+
+// Sync commits written data to stable storage.
+func (s *FileSink) Sync() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("sync file: %w", err)
+	}
+	s.dirty = false
+	s.lastSync = time.Now()
+
+	return nil
+}
+
+// Close syncs and closes the file. Close the Packer writing into the sink
+// first, so that the EOS page and the patched header reach the file.
+func (s *FileSink) Close() error {
+	if err := s.Sync(); err != nil {
+		s.f.Close()
+		return err
+	}
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package packer_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	extogg "mccoy.space/g/ogg"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+)
+
+func TestFileSink(t *testing.T) {
+	sourcePCMData := pcmData(t, "testdata/48k_1ch.pcm")
+	fname := filepath.Join(t.TempDir(), "out.ogg")
+
+	sink, err := packer.NewFileSink(fname, 0)
+	if err != nil {
+		t.Fatalf("create file sink: %s", err.Error())
+	}
+
+	p, err := packer.New(packer.WithWriter(sink))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+
+	for i := 0; i < len(sourcePCMData); i += 2048 {
+		end := min(i+2048, len(sourcePCMData))
+		if err := p.SendPCMChunk(sourcePCMData[i:end]); err != nil {
+			t.Fatalf("send PCM chunk: %s", err.Error())
+		}
+
+		// Whatever is on disk must always decode as complete pages.
+		pages := oggPages(t, readFile(t, fname))
+		if len(pages) == 0 || pages[len(pages)-1].Type&extogg.EOS != 0 {
+			t.Fatalf("unexpected pages on disk after chunk %d", i/2048)
+		}
+	}
+
+	if _, err := p.GetResult(); err != packer.ErrStreaming {
+		t.Fatalf("GetResult error should be %v, current %v", packer.ErrStreaming, err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("close packer: %s", err.Error())
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close file sink: %s", err.Error())
+	}
+
+	pages := oggPages(t, readFile(t, fname))
+	if pages[len(pages)-1].Type&extogg.EOS == 0 {
+		t.Fatal("last page should have the EOS flag")
+	}
+
+	tags := string(pages[1].Packets[0])
+	if !strings.HasPrefix(tags, "OpusTags") || !strings.Contains(tags, "DURATION=00:00:04.920") {
+		t.Fatalf("tags header should contain the final duration, current %q", tags)
+	}
+}
+
+func readFile(t *testing.T, fname string) []byte {
+	t.Helper()
+
+	d, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("read file: %s", err.Error())
+	}
+
+	return d
+}
+
+func oggPages(t *testing.T, oggData []byte) []extogg.Page {
+	t.Helper()
+
+	oggDecoder := extogg.NewDecoder(bytes.NewReader(oggData))
+
+	var pages []extogg.Page
+	for {
+		page, err := oggDecoder.Decode()
+		if err != nil {
+			break
+		}
+		pages = append(pages, page)
+	}
+
+	return pages
+}
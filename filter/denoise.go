@@ -0,0 +1,204 @@
+package filter
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/internal/dsp"
+)
+
+const (
+	oversubtraction = 2.0 // times the noise power removed from every bin
+	gainSmoothing   = 0.3 // weight of the previous gain of a bin
+	profileUpdate   = 0.05
+	noiseLikeFrame  = 2.0 // frames below this many times the noise power update the profile
+)
+
+// NoiseConfig configures NoiseReducer.
+type NoiseConfig struct {
+	// Reduction is the maximum attenuation of noise in dB. Defaults to
+	// 12 dB; more makes the remaining noise sound watery.
+	Reduction float64
+	// Learn is the length of the start of the stream the noise profile is
+	// learned from, which should contain only background noise. Defaults
+	// to 500 ms. Later frames close to the profile keep adapting it.
+	Learn time.Duration
+}
+
+// noiseReducer performs spectral subtraction in a short-time Fourier
+// transform with half overlapping square root Hann windows of about
+// 20 ms, which reconstruct the signal exactly where nothing is removed.
+type noiseReducer struct {
+	channels int
+	size     int // FFT size
+	hop      int
+	window   []float64
+	floor    float64 // minimum gain
+	learn    int     // frames the profile is learned from
+
+	in     [][]float64 // per channel the input of the next frame
+	out    [][]float64 // per channel the overlap-added output
+	noise  [][]float64 // per channel the noise power per bin
+	gains  [][]float64 // per channel the gain per bin of the last frame
+	frames int
+	fft    []complex128
+
+	inputs  int // samples per channel processed
+	outputs int // samples per channel returned
+	buf     []int16
+}
+
+// NoiseReducer removes stationary background noise such as hiss with
+// spectral subtraction. The noise profile is learned at the start of the
+// stream, which passes unchanged meanwhile. The filter adds a latency of
+// half its window, and works the same for any sample rate and run.
+func NoiseReducer(sampleRate, channels int, cfg NoiseConfig) Filter {
+	if cfg.Reduction == 0 {
+		cfg.Reduction = 12
+	}
+	if cfg.Learn == 0 {
+		cfg.Learn = 500 * time.Millisecond
+	}
+
+	size := dsp.NextPowerOfTwo(sampleRate / 50)
+	window := dsp.Hann(size)
+	for i, w := range window {
+		window[i] = math.Sqrt(w)
+	}
+	f := &noiseReducer{
+		channels: channels,
+		size:     size,
+		hop:      size / 2,
+		window:   window,
+		floor:    math.Pow(10, -cfg.Reduction/20),
+		learn:    max(int(cfg.Learn*time.Duration(sampleRate)/time.Second)/(size/2), 1),
+		fft:      make([]complex128, size),
+	}
+	f.reset()
+
+	return f
+}
+
+func (f *noiseReducer) reset() {
+	f.in = make([][]float64, f.channels)
+	f.out = make([][]float64, f.channels)
+	f.noise = make([][]float64, f.channels)
+	f.gains = make([][]float64, f.channels)
+	for ch := 0; ch < f.channels; ch++ {
+		f.in[ch] = make([]float64, f.size-f.hop, f.size)
+		f.out[ch] = make([]float64, f.size)
+		f.noise[ch] = make([]float64, f.size/2+1)
+		f.gains[ch] = make([]float64, f.size/2+1)
+	}
+	f.frames = 0
+	f.inputs = 0
+	f.outputs = 0
+}
+
+func (f *noiseReducer) Process(pcm []int16) []int16 {
+	f.buf = f.buf[:0]
+	for i := 0; i+f.channels <= len(pcm); i += f.channels {
+		f.add(pcm[i : i+f.channels])
+	}
+	return f.buf
+}
+
+func (f *noiseReducer) Flush() []int16 {
+	f.buf = f.buf[:0]
+	silence := make([]int16, f.channels)
+	end := f.inputs + f.hop
+	for f.outputs < end {
+		f.add(silence)
+	}
+	f.buf = f.buf[:len(f.buf)-(f.outputs-end)*f.channels]
+
+	f.reset()
+	return f.buf
+}
+
+func (f *noiseReducer) Latency() int {
+	return f.hop
+}
+
+func (f *noiseReducer) add(frame []int16) {
+	for ch, v := range frame {
+		f.in[ch] = append(f.in[ch], float64(v))
+	}
+	f.inputs++
+	if len(f.in[0]) < f.size {
+		return
+	}
+
+	for ch := range f.in {
+		f.processFrame(ch)
+		copy(f.in[ch], f.in[ch][f.hop:])
+		f.in[ch] = f.in[ch][:f.size-f.hop]
+	}
+	f.frames++
+
+	for i := 0; i < f.hop; i++ {
+		for ch := range f.out {
+			f.buf = append(f.buf, toInt16(f.out[ch][i]))
+		}
+	}
+	for ch := range f.out {
+		copy(f.out[ch], f.out[ch][f.hop:])
+		clear(f.out[ch][f.size-f.hop:])
+	}
+	f.outputs += f.hop
+}
+
+// processFrame filters the frame in the input buffer of a channel and adds
+// it to its output.
+func (f *noiseReducer) processFrame(ch int) {
+	for i, v := range f.in[ch] {
+		f.fft[i] = complex(v*f.window[i], 0)
+	}
+	dsp.FFT(f.fft)
+
+	noise, gains := f.noise[ch], f.gains[ch]
+	bins := len(noise)
+	if f.frames < f.learn {
+		for k := 0; k < bins; k++ {
+			noise[k] += power(f.fft[k]) / float64(f.learn)
+			gains[k] = 1
+		}
+	} else {
+		framePower, noisePower := 0.0, 0.0
+		for k := 0; k < bins; k++ {
+			framePower += power(f.fft[k])
+			noisePower += noise[k]
+		}
+		update := framePower < noiseLikeFrame*noisePower
+
+		for k := 0; k < bins; k++ {
+			p := power(f.fft[k])
+			if update {
+				noise[k] += (p - noise[k]) * profileUpdate
+			}
+
+			g := f.floor
+			if p > 0 {
+				g = max(math.Sqrt(max(1-oversubtraction*noise[k]/p, 0)), f.floor)
+			}
+			g = (1-gainSmoothing)*g + gainSmoothing*gains[k]
+			gains[k] = g
+
+			f.fft[k] *= complex(g, 0)
+			if k > 0 && k < f.size-k {
+				f.fft[f.size-k] *= complex(g, 0)
+			}
+		}
+	}
+
+	dsp.IFFT(f.fft)
+	for i, c := range f.fft {
+		f.out[ch][i] += real(c) * f.window[i]
+	}
+}
+
+func power(c complex128) float64 {
+	a := cmplx.Abs(c)
+	return a * a
+}
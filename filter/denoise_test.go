@@ -0,0 +1,86 @@
+package filter_test
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/filter"
+)
+
+func TestNoiseReducer(t *testing.T) {
+	for _, rate := range []int{8000, 12000, 16000, 24000, 48000} {
+		noisy, clean := noisyTone(rate)
+
+		run := func() []int16 {
+			f := filter.NoiseReducer(rate, 1, filter.NoiseConfig{})
+			var out []int16
+			for i := 0; i < len(noisy); i += 999 {
+				out = append(out, f.Process(noisy[i:min(i+999, len(noisy))])...)
+			}
+			out = append(out, f.Flush()...)
+			if len(out) != len(noisy)+f.Latency() {
+				t.Fatalf("output length at %d Hz should be equal %d, current %d", rate, len(noisy)+f.Latency(), len(out))
+			}
+			return out[f.Latency():]
+		}
+		out := run()
+		if !slices.Equal(out, run()) {
+			t.Fatalf("output at %d Hz should be the same for every run", rate)
+		}
+
+		// The noise in the pause after learning is reduced by more than 8 dB.
+		pause := [2]int{rate * 7 / 10, rate}
+		if got := rms(out, clean, pause) / rms(noisy, clean, pause); got > math.Pow(10, -8.0/20) {
+			t.Fatalf("noise at %d Hz should be reduced by 8 dB, current %.1f dB", rate, 20*math.Log10(got))
+		}
+		// The tone keeps less noise than before.
+		tone := [2]int{rate * 12 / 10, rate * 19 / 10}
+		if got := rms(out, clean, tone) / rms(noisy, clean, tone); got > math.Pow(10, -3.0/20) {
+			t.Fatalf("noise on the tone at %d Hz should be reduced by 3 dB, current %.1f dB", rate, 20*math.Log10(got))
+		}
+	}
+}
+
+func TestGate(t *testing.T) {
+	noisy, clean := noisyTone(16000)
+
+	f := filter.Gate(16000, 1, filter.GateConfig{Threshold: -30})
+	out := f.Process(noisy)
+
+	pause := [2]int{8000, 16000}
+	if got := rms(out, clean, pause) / rms(noisy, clean, pause); got > math.Pow(10, -29.0/20) {
+		t.Fatalf("noise in pauses should be reduced by 30 dB, current %.1f dB", 20*math.Log10(got))
+	}
+	tone := [2]int{17000, 32000}
+	if got := rms(out, clean, tone) / rms(noisy, clean, tone); math.Abs(got-1) > 0.01 {
+		t.Fatalf("tone should pass unchanged, current %.1f dB", 20*math.Log10(got))
+	}
+}
+
+// noisyTone returns 1 s of white noise followed by 1 s of a 440 Hz tone
+// with the same noise, and the signal without the noise.
+func noisyTone(sampleRate int) ([]int16, []int16) {
+	rnd := rand.New(rand.NewSource(1))
+	noisy := make([]int16, 2*sampleRate)
+	clean := make([]int16, 2*sampleRate)
+	for i := range noisy {
+		if i >= sampleRate {
+			clean[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/float64(sampleRate)))
+		}
+		noisy[i] = clean[i] + int16((rnd.Float64()*2-1)*600)
+	}
+	return noisy, clean
+}
+
+// rms returns the root mean square difference of pcm and clean in the
+// range of samples.
+func rms(pcm, clean []int16, r [2]int) float64 {
+	sum := 0.0
+	for i := r[0]; i < r[1]; i++ {
+		d := float64(pcm[i]) - float64(clean[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(r[1]-r[0]))
+}
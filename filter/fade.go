@@ -0,0 +1,76 @@
+package filter
+
+import (
+	"math"
+	"time"
+)
+
+// fade raises the level at the start and lowers it at the end of a stream
+// along a half cosine.
+type fade struct {
+	channels int
+	in, out  int // samples per channel
+
+	pos  int     // samples per channel processed
+	tail []int16 // the last out samples, held back until Flush
+	buf  []int16
+}
+
+// Fade fades the stream in over in and out over out. The fade-out needs to
+// know where the stream ends, so the last out of audio is held back until
+// Flush.
+func Fade(sampleRate, channels int, in, out time.Duration) Filter {
+	samples := func(d time.Duration) int {
+		return int(d * time.Duration(sampleRate) / time.Second)
+	}
+	return &fade{channels: channels, in: samples(in), out: samples(out)}
+}
+
+func (f *fade) Process(pcm []int16) []int16 {
+	f.buf = f.buf[:0]
+
+	f.tail = append(f.tail, pcm...)
+	ready := len(f.tail) - f.out*f.channels
+	if ready <= 0 {
+		return f.buf
+	}
+	ready = ready / f.channels * f.channels
+
+	for i, v := range f.tail[:ready] {
+		g := 1.0
+		if n := f.pos + i/f.channels; n < f.in {
+			g = curve(float64(n) / float64(f.in))
+		}
+		f.buf = append(f.buf, int16(math.Round(float64(v)*g)))
+	}
+	f.pos += ready / f.channels
+	f.tail = append(f.tail[:0], f.tail[ready:]...)
+
+	return f.buf
+}
+
+func (f *fade) Flush() []int16 {
+	f.buf = f.buf[:0]
+
+	n := len(f.tail) / f.channels
+	for i, v := range f.tail {
+		g := curve(float64(n-i/f.channels) / float64(f.out+1))
+		if p := f.pos + i/f.channels; p < f.in {
+			g *= curve(float64(p) / float64(f.in))
+		}
+		f.buf = append(f.buf, int16(math.Round(float64(v)*g)))
+	}
+
+	f.pos = 0
+	f.tail = f.tail[:0]
+	return f.buf
+}
+
+func (f *fade) Latency() int {
+	return 0
+}
+
+// curve maps the position 0..1 in a fade to a gain from 0 to 1.
+func curve(x float64) float64 {
+	return 0.5 - 0.5*math.Cos(math.Pi*x)
+}
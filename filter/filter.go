@@ -0,0 +1,92 @@
+// Package filter provides PCM processing stages that run in front of the
+// encoder, see packer.WithFilters.
+package filter
+
+import "math"
+
+// Filter processes interleaved 16-bit PCM.
+//
+// Over a whole stream a filter outputs exactly Latency more samples per
+// channel than it was given: the output is delayed by Latency, so the
+// extra samples come first and are skipped by the decoder as pre-skip.
+// Apart from that a filter may hold samples back, for instance to see
+// the end of the stream, and return them later.
+type Filter interface {
+	// Process filters samples and returns the output that is ready. The
+	// result is only valid until the next call.
+	Process(pcm []int16) []int16
+	// Flush returns the remaining output at the end of a stream and resets
+	// the filter, so it can be used for the next one.
+	Flush() []int16
+	// Latency returns the delay of the output in samples per channel.
+	Latency() int
+}
+
+// sampleFilter is a Filter without latency that maps every sample on its
+// own, given its channel.
+type sampleFilter struct {
+	channels int
+	fn       func(ch int, v float64) float64
+	reset    func()
+	out      []int16
+}
+
+func (f *sampleFilter) Process(pcm []int16) []int16 {
+	f.out = f.out[:0]
+	for i, v := range pcm {
+		f.out = append(f.out, toInt16(f.fn(i%f.channels, float64(v))))
+	}
+	return f.out
+}
+
+func (f *sampleFilter) Flush() []int16 {
+	if f.reset != nil {
+		f.reset()
+	}
+	return nil
+}
+
+func (f *sampleFilter) Latency() int {
+	return 0
+}
+
+// Gain changes the level of the signal by db decibels. Samples beyond the
+// 16-bit range are clipped.
+func Gain(db float64) Filter {
+	g := math.Pow(10, db/20)
+	return &sampleFilter{
+		channels: 1,
+		fn:       func(_ int, v float64) float64 { return v * g },
+	}
+}
+
+// HardClip limits samples to ceiling in dBFS by cutting off the peaks.
+func HardClip(ceiling float64) Filter {
+	c := math.Pow(10, ceiling/20) * 32767
+	return &sampleFilter{
+		channels: 1,
+		fn:       func(_ int, v float64) float64 { return max(min(v, c), -c) },
+	}
+}
+
+// SoftClip limits samples to ceiling in dBFS with a smooth curve. Samples
+// up to 6 dB below the ceiling pass unchanged, louder ones are bent
+// towards the ceiling without a kink.
+func SoftClip(ceiling float64) Filter {
+	c := math.Pow(10, ceiling/20) * 32767
+	knee := c / 2
+	return &sampleFilter{
+		channels: 1,
+		fn: func(_ int, v float64) float64 {
+			a := math.Abs(v)
+			if a <= knee {
+				return v
+			}
+			return math.Copysign(knee+(c-knee)*math.Tanh((a-knee)/(c-knee)), v)
+		},
+	}
+}
+
+func toInt16(v float64) int16 {
+	return int16(max(min(math.Round(v), math.MaxInt16), math.MinInt16))
+}
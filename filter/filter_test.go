@@ -0,0 +1,92 @@
+package filter_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/filter"
+)
+
+func TestGainAndClip(t *testing.T) {
+	in := []int16{1000, -1000, 20000, -30000}
+
+	tests := []struct {
+		name string
+		f    filter.Filter
+		want []int16
+	}{
+		{name: "gain", f: filter.Gain(6), want: []int16{1995, -1995, 32767, -32768}},
+		{name: "hard clip", f: filter.HardClip(-6), want: []int16{1000, -1000, 16422, -16422}},
+		{name: "soft clip", f: filter.SoftClip(-6), want: []int16{1000, -1000, 15542, -16341}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.f.Process(in)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("sample %d should be equal %d, current %d", i, tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHighPass(t *testing.T) {
+	pcm := make([]int16, 2*48000)
+	for i := range pcm {
+		pcm[i] = 5000 + int16(1000*math.Sin(2*math.Pi*1000*float64(i/2)/48000))
+	}
+
+	f := filter.DCBlocker(48000, 2)
+	out := append([]int16(nil), f.Process(pcm)...)
+	out = append(out, f.Flush()...)
+
+	// After settling the offset is gone and the tone is unchanged.
+	sum, peak := 0, 0
+	for _, v := range out[48000:] {
+		sum += int(v)
+		peak = max(peak, int(v))
+	}
+	if mean := sum / 48000; mean < -5 || mean > 5 {
+		t.Fatalf("mean should be close to 0, current %d", mean)
+	}
+	if peak < 990 || peak > 1010 {
+		t.Fatalf("peak should be close to 1000, current %d", peak)
+	}
+}
+
+func TestFade(t *testing.T) {
+	pcm := make([]int16, 48000)
+	for i := range pcm {
+		pcm[i] = 10000
+	}
+
+	f := filter.Fade(48000, 1, 100*time.Millisecond, 200*time.Millisecond)
+	var out []int16
+	for i := 0; i < len(pcm); i += 1000 {
+		out = append(out, f.Process(pcm[i:i+1000])...)
+	}
+	out = append(out, f.Flush()...)
+
+	if len(out) != len(pcm) {
+		t.Fatalf("output length should be equal %d, current %d", len(pcm), len(out))
+	}
+	checks := []struct {
+		pos  int
+		want int16
+	}{
+		{pos: 0, want: 0},
+		{pos: 2400, want: 5000},
+		{pos: 4800, want: 10000},
+		{pos: 38400, want: 10000},
+		{pos: 43200, want: 5000},
+		{pos: 47999, want: 0},
+	}
+	for _, c := range checks {
+		if got := out[c.pos]; got < c.want-10 || got > c.want+10 {
+			t.Fatalf("sample %d should be close to %d, current %d", c.pos, c.want, got)
+		}
+	}
+}
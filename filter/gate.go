@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"math"
+	"time"
+)
+
+// GateConfig configures Gate. Zero fields take their defaults.
+type GateConfig struct {
+	// Threshold is the level in dBFS above which the gate opens. Defaults
+	// to -50 dBFS.
+	Threshold float64
+	// Range is the attenuation in dB of the closed gate. Defaults to
+	// 30 dB.
+	Range float64
+	// Attack is the time the gate takes to open. Defaults to 5 ms.
+	Attack time.Duration
+	// Hold keeps the gate open after the level fell below the threshold.
+	// Defaults to 50 ms.
+	Hold time.Duration
+	// Release is the time the gate takes to close. Defaults to 100 ms.
+	Release time.Duration
+}
+
+// gate attenuates the signal while its level is below a threshold. All
+// channels share one gain, so the stereo image does not move.
+type gate struct {
+	channels  int
+	threshold float64 // linear
+	floor     float64 // gain of the closed gate
+	attack    float64 // per sample smoothing coefficients
+	release   float64
+	decay     float64 // envelope follower
+	hold      int     // samples
+
+	env  float64
+	gain float64
+	held int
+	buf  []int16
+}
+
+// Gate mutes background noise in the pauses of a signal. It introduces no
+// latency.
+func Gate(sampleRate, channels int, cfg GateConfig) Filter {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = -50
+	}
+	if cfg.Range == 0 {
+		cfg.Range = 30
+	}
+	if cfg.Attack == 0 {
+		cfg.Attack = 5 * time.Millisecond
+	}
+	if cfg.Hold == 0 {
+		cfg.Hold = 50 * time.Millisecond
+	}
+	if cfg.Release == 0 {
+		cfg.Release = 100 * time.Millisecond
+	}
+
+	coef := func(d time.Duration) float64 {
+		return math.Exp(-float64(time.Second) / (float64(d) * float64(sampleRate)))
+	}
+	g := &gate{
+		channels:  channels,
+		threshold: math.Pow(10, cfg.Threshold/20) * 32768,
+		floor:     math.Pow(10, -cfg.Range/20),
+		attack:    coef(cfg.Attack),
+		release:   coef(cfg.Release),
+		decay:     coef(10 * time.Millisecond),
+		hold:      int(cfg.Hold * time.Duration(sampleRate) / time.Second),
+	}
+	g.gain = g.floor
+
+	return g
+}
+
+func (g *gate) Process(pcm []int16) []int16 {
+	g.buf = g.buf[:0]
+	for i := 0; i+g.channels <= len(pcm); i += g.channels {
+		frame := pcm[i : i+g.channels]
+
+		peak := 0.0
+		for _, v := range frame {
+			peak = max(peak, math.Abs(float64(v)))
+		}
+		g.env = max(peak, g.env*g.decay)
+
+		target := g.floor
+		switch {
+		case g.env > g.threshold:
+			g.held = g.hold
+			target = 1
+		case g.held > 0:
+			g.held--
+			target = 1
+		}
+		coef := g.release
+		if target > g.gain {
+			coef = g.attack
+		}
+		g.gain = target + (g.gain-target)*coef
+
+		for _, v := range frame {
+			g.buf = append(g.buf, toInt16(float64(v)*g.gain))
+		}
+	}
+	return g.buf
+}
+
+func (g *gate) Flush() []int16 {
+	g.env = 0
+	g.gain = g.floor
+	g.held = 0
+	return nil
+}
+
+func (g *gate) Latency() int {
+	return 0
+}
@@ -0,0 +1,44 @@
+package filter
+
+import (
+	"math"
+
+	"github.com/paveldroo/go-ogg-packer/internal/dsp"
+)
+
+// HighPass removes frequencies below cutoff in Hz with a second order
+// Butterworth filter per channel. It introduces no latency.
+func HighPass(sampleRate, channels int, cutoff float64) Filter {
+	k := math.Tan(math.Pi * cutoff / float64(sampleRate))
+	q := math.Sqrt2 / 2
+	a0 := 1 + k/q + k*k
+	proto := dsp.Biquad{
+		B0: 1 / a0,
+		B1: -2 / a0,
+		B2: 1 / a0,
+		A1: 2 * (k*k - 1) / a0,
+		A2: (1 - k/q + k*k) / a0,
+	}
+
+	filters := make([]dsp.Biquad, channels)
+	for i := range filters {
+		filters[i] = proto
+	}
+
+	return &sampleFilter{
+		channels: channels,
+		fn: func(ch int, v float64) float64 {
+			return filters[ch].Process(v)
+		},
+		reset: func() {
+			for i := range filters {
+				filters[i].Reset()
+			}
+		},
+	}
+}
+
+// DCBlocker removes a DC offset, a high-pass at 10 Hz.
+func DCBlocker(sampleRate, channels int) Filter {
+	return HighPass(sampleRate, channels, 10)
+}
@@ -0,0 +1,28 @@
+// Package dsp holds signal processing building blocks shared by the
+// analysis and filter packages.
+package dsp
+
+// Biquad is a second order IIR filter in transposed direct form II,
+//
+//	y[n] = B0 x[n] + B1 x[n-1] + B2 x[n-2] - A1 y[n-1] - A2 y[n-2],
+//
+// with coefficients normalized so that a0 is 1.
+type Biquad struct {
+	B0, B1, B2 float64
+	A1, A2     float64
+
+	z1, z2 float64
+}
+
+// Process filters one sample.
+func (f *Biquad) Process(x float64) float64 {
+	y := f.B0*x + f.z1
+	f.z1 = f.B1*x - f.A1*y + f.z2
+	f.z2 = f.B2*x - f.A2*y
+	return y
+}
+
+// Reset clears the filter state.
+func (f *Biquad) Reset() {
+	f.z1, f.z2 = 0, 0
+}
@@ -0,0 +1,67 @@
+package dsp
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// FFT transforms x in place with an iterative radix-2 Cooley-Tukey FFT.
+// The length of x has to be a power of two.
+func FFT(x []complex128) {
+	n := len(x)
+	if n&(n-1) != 0 {
+		panic("dsp: FFT length is not a power of two")
+	}
+	if n < 2 {
+		return
+	}
+
+	shift := 64 - bits.TrailingZeros(uint(n))
+	for i := range x {
+		if j := int(bits.Reverse64(uint64(i)) >> shift); j > i {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], w*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				w *= step
+			}
+		}
+	}
+}
+
+// IFFT is the inverse of FFT, including the 1/n scaling.
+func IFFT(x []complex128) {
+	for i, v := range x {
+		x[i] = cmplx.Conj(v)
+	}
+	FFT(x)
+	scale := 1 / float64(len(x))
+	for i, v := range x {
+		x[i] = cmplx.Conj(v) * complex(scale, 0)
+	}
+}
+
+// NextPowerOfTwo returns the smallest power of two that is at least n.
+func NextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// Hann returns a periodic Hann window of length n.
+func Hann(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	return w
+}
@@ -0,0 +1,39 @@
+package dsp_test
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/internal/dsp"
+)
+
+func TestFFT(t *testing.T) {
+	x := make([]complex128, 64)
+	for i := range x {
+		x[i] = complex(math.Sin(float64(i)*0.3)+float64(i%5), math.Cos(float64(i)))
+	}
+
+	// Compare with the direct evaluation of the DFT.
+	want := make([]complex128, len(x))
+	for k := range want {
+		for n, v := range x {
+			want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*n)/float64(len(x))))
+		}
+	}
+
+	got := append([]complex128(nil), x...)
+	dsp.FFT(got)
+	for k := range want {
+		if cmplx.Abs(got[k]-want[k]) > 1e-9 {
+			t.Fatalf("bin %d should be equal %v, current %v", k, want[k], got[k])
+		}
+	}
+
+	dsp.IFFT(got)
+	for n := range x {
+		if cmplx.Abs(got[n]-x[n]) > 1e-9 {
+			t.Fatalf("sample %d should be equal %v after the inverse, current %v", n, x[n], got[n])
+		}
+	}
+}
@@ -0,0 +1,328 @@
+// Package jitter puts chunks of audio that arrive out of order, such as
+// from WebSocket or UDP clients, back in sequence before they are passed
+// on to a packer.
+package jitter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	opus "gopkg.in/hraban/opus.v2"
+
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+const (
+	defaultMaxHold   = 200 * time.Millisecond
+	defaultFrameSize = 20 * time.Millisecond
+	// window is how many sequence numbers before the next expected one
+	// are remembered to tell late chunks from duplicates, and how far
+	// ahead of it a chunk may be before the sequence counts as restarted.
+	window = 1024
+	// maxPacketSamples is the longest duration of an opus packet, 120 ms
+	// at 48 kHz.
+	maxPacketSamples = 5760
+)
+
+var (
+	ErrClosed = errors.New("jitter: buffer is closed")
+	// ErrMixed is returned when PCM and Opus packets are pushed into the
+	// same buffer.
+	ErrMixed = errors.New("jitter: buffer holds both PCM and opus packets")
+)
+
+// Sink receives the PCM in sequence. packer.Packer, packer.Rotator and
+// packer.Splitter are sinks.
+type Sink interface {
+	SendPCMChunk(chunk []int16) error
+}
+
+// Concealment selects what replaces a lost chunk.
+type Concealment int
+
+const (
+	// Silence replaces a lost chunk with silence of the length of the
+	// chunk before it, or of Config.FrameSize if there is none.
+	Silence Concealment = iota
+	// PLC uses the packet loss concealment of the opus decoder for
+	// buffers fed with PushOpus, which continues the preceding audio and
+	// fades it out. PCM chunks are concealed with silence.
+	PLC
+)
+
+// Config configures a Buffer.
+type Config struct {
+	// SampleRate and Channels describe the PCM passed to the sink. Opus
+	// packets are decoded to them.
+	SampleRate int
+	Channels   int
+	// MaxHold is how long a missing chunk is waited for before it is
+	// given up and concealed: until as much audio has queued up behind
+	// it, or until the chunks behind it have been held that long, see
+	// Advance. Defaults to 200 ms.
+	MaxHold time.Duration
+	Conceal Concealment
+	// Now returns the arrival time of chunks. Defaults to time.Now.
+	Now func() time.Time
+	// FrameSize is how long a lost chunk is concealed for before any
+	// chunk has been passed on; later ones take as long as the chunk
+	// before them. Defaults to 20 ms.
+	FrameSize time.Duration
+}
+
+// Stats counts what happened to the chunks pushed into a Buffer.
+type Stats struct {
+	// Delivered chunks were passed on in sequence.
+	Delivered int
+	// Lost chunks did not arrive within MaxHold, or could not be decoded,
+	// and were concealed.
+	Lost int
+	// Late chunks arrived after their turn, usually after they had been
+	// concealed, and were dropped.
+	Late int
+	// Duplicate chunks had already been received and were dropped.
+	Duplicate int
+}
+
+type entry struct {
+	pcm     []int16
+	packet  []byte
+	arrived time.Time
+}
+
+// Buffer reorders chunks by a 32-bit sequence number, which may wrap
+// around, and passes them on to a sink. Sequence numbers are expected to
+// increase by one per chunk; the first chunk pushed sets where the
+// sequence starts. A client that stops sending leaves the chunks behind a
+// missing one queued until Advance is called. It is not safe for
+// concurrent use.
+type Buffer struct {
+	cfg     Config
+	sink    Sink
+	maxHold int // samples per channel
+
+	started bool
+	closed  bool
+	next    uint32 // sequence number of the next chunk to deliver
+	queue   map[uint32]entry
+	queued  int             // samples per channel in queue
+	history map[uint32]bool // whether the last window chunks were concealed
+	last    int             // samples per channel of the last chunk
+	packets bool            // the buffer holds opus packets
+	decoder *opus.Decoder
+	decoded []int16
+	stats   Stats
+}
+
+// New returns a Buffer that passes chunks on to sink.
+func New(cfg Config, sink Sink) *Buffer {
+	if cfg.MaxHold == 0 {
+		cfg.MaxHold = defaultMaxHold
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.FrameSize == 0 {
+		cfg.FrameSize = defaultFrameSize
+	}
+
+	return &Buffer{
+		cfg:     cfg,
+		sink:    sink,
+		maxHold: int(cfg.MaxHold * time.Duration(cfg.SampleRate) / time.Second),
+		queue:   make(map[uint32]entry),
+		history: make(map[uint32]bool),
+	}
+}
+
+// Push adds a chunk of interleaved PCM with sequence number seq. Chunks
+// that complete the sequence are passed on to the sink right away. The
+// chunk is copied.
+func (b *Buffer) Push(seq uint32, pcm []int16) error {
+	if b.packets {
+		return ErrMixed
+	}
+	return b.push(seq, entry{pcm: append([]int16(nil), pcm...)})
+}
+
+// PushOpus adds an opus packet with sequence number seq. Packets are
+// decoded in sequence before they are passed on to the sink, so lost ones
+// can be concealed with PLC. The packet is copied.
+func (b *Buffer) PushOpus(seq uint32, packet []byte) error {
+	if !b.packets {
+		if b.started {
+			return ErrMixed
+		}
+		d, err := opus.NewDecoder(b.cfg.SampleRate, b.cfg.Channels)
+		if err != nil {
+			return fmt.Errorf("create opus decoder: %w", err)
+		}
+		b.decoder = d
+		b.decoded = make([]int16, maxPacketSamples*b.cfg.SampleRate/oggopus.SampleRate*b.cfg.Channels)
+		b.packets = true
+	}
+	return b.push(seq, entry{packet: append([]byte(nil), packet...)})
+}
+
+// Advance gives up the missing chunks that the chunks queued behind them
+// have waited for longer than MaxHold at now, and passes those on. Call it
+// regularly, for example from a ticker, so that output keeps going when a
+// client stalls.
+func (b *Buffer) Advance(now time.Time) error {
+	if b.closed {
+		return ErrClosed
+	}
+	return b.release(now)
+}
+
+// Close passes on all queued chunks, concealing the ones still missing.
+// The sink is not closed.
+func (b *Buffer) Close() error {
+	if b.closed {
+		return ErrClosed
+	}
+	b.closed = true
+
+	for len(b.queue) > 0 {
+		if err := b.advance(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the counts of delivered, lost, late and duplicate chunks.
+func (b *Buffer) Stats() Stats {
+	return b.stats
+}
+
+func (b *Buffer) push(seq uint32, e entry) error {
+	if b.closed {
+		return ErrClosed
+	}
+	if !b.started {
+		b.started = true
+		b.next = seq
+	}
+
+	d := int32(seq - b.next)
+	if d < 0 {
+		if concealed, ok := b.history[seq]; ok && !concealed {
+			b.stats.Duplicate++
+		} else {
+			delete(b.history, seq)
+			b.stats.Late++
+		}
+		return nil
+	}
+	if d >= window {
+		// The sender skipped far ahead, for example after a reconnect.
+		for len(b.queue) > 0 {
+			if err := b.advance(); err != nil {
+				return err
+			}
+		}
+		b.next = seq
+	}
+	if _, ok := b.queue[seq]; ok {
+		b.stats.Duplicate++
+		return nil
+	}
+
+	e.arrived = b.cfg.Now()
+	b.queue[seq] = e
+	b.queued += b.samples(e)
+
+	return b.release(e.arrived)
+}
+
+// release passes on the chunks at the head of the queue, giving up missing
+// ones once MaxHold of audio is queued behind them or has been held for
+// MaxHold at now.
+func (b *Buffer) release(now time.Time) error {
+	for len(b.queue) > 0 {
+		if _, ok := b.queue[b.next]; !ok && b.queued <= b.maxHold && !b.expired(now) {
+			return nil
+		}
+		if err := b.advance(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expired reports whether a queued chunk arrived more than MaxHold before
+// now.
+func (b *Buffer) expired(now time.Time) bool {
+	for _, e := range b.queue {
+		if now.Sub(e.arrived) > b.cfg.MaxHold {
+			return true
+		}
+	}
+	return false
+}
+
+// advance delivers the next chunk, or conceals it if it is missing.
+func (b *Buffer) advance() error {
+	seq := b.next
+	b.next++
+	delete(b.history, seq-window)
+
+	e, ok := b.queue[seq]
+	b.history[seq] = !ok
+	if !ok {
+		b.stats.Lost++
+		return b.conceal()
+	}
+
+	delete(b.queue, seq)
+	b.queued -= b.samples(e)
+
+	pcm := e.pcm
+	if e.packet != nil {
+		n, err := b.decoder.Decode(e.packet, b.decoded)
+		if err != nil {
+			// A packet that fails to decode is concealed like a lost one.
+			b.history[seq] = true
+			b.stats.Lost++
+			if n := b.samples(e); n > 0 {
+				b.last = n
+			}
+			return b.conceal()
+		}
+		pcm = b.decoded[:n*b.cfg.Channels]
+	}
+	b.stats.Delivered++
+	b.last = len(pcm) / b.cfg.Channels
+
+	return b.sink.SendPCMChunk(pcm)
+}
+
+func (b *Buffer) conceal() error {
+	n := b.last
+	if n == 0 {
+		n = int(b.cfg.FrameSize * time.Duration(b.cfg.SampleRate) / time.Second)
+	}
+	pcm := make([]int16, n*b.cfg.Channels)
+	if b.cfg.Conceal == PLC && b.decoder != nil && len(pcm) > 0 {
+		if err := b.decoder.DecodePLC(pcm); err != nil {
+			clear(pcm)
+		}
+	}
+	return b.sink.SendPCMChunk(pcm)
+}
+
+// samples returns the duration of a chunk in samples per channel.
+func (b *Buffer) samples(e entry) int {
+	if e.packet == nil {
+		return len(e.pcm) / b.cfg.Channels
+	}
+	n, err := oggopus.PacketSamples(e.packet)
+	if err != nil {
+		return 0
+	}
+	return n * b.cfg.SampleRate / oggopus.SampleRate
+}
@@ -0,0 +1,226 @@
+package jitter_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/jitter"
+	"github.com/paveldroo/go-ogg-packer/opus"
+)
+
+type sink struct {
+	chunks [][]int16
+}
+
+func (s *sink) SendPCMChunk(chunk []int16) error {
+	s.chunks = append(s.chunks, append([]int16(nil), chunk...))
+	return nil
+}
+
+// firsts returns the first sample of every chunk received.
+func (s *sink) firsts() []int16 {
+	var v []int16
+	for _, c := range s.chunks {
+		v = append(v, c[0])
+	}
+	return v
+}
+
+func chunk(seq uint32) []int16 {
+	pcm := make([]int16, 160)
+	for i := range pcm {
+		pcm[i] = int16(seq%1000) + 1
+	}
+	return pcm
+}
+
+func TestBuffer(t *testing.T) {
+	out := &sink{}
+	b := jitter.New(jitter.Config{SampleRate: 8000, Channels: 1, MaxHold: 60 * time.Millisecond}, out)
+
+	for _, seq := range []uint32{0, 2, 1, 4, 5, 6} {
+		if err := b.Push(seq, chunk(seq)); err != nil {
+			t.Fatalf("push %d: %s", seq, err.Error())
+		}
+	}
+	// 3 is still waited for.
+	if want := []int16{1, 2, 3}; !reflect.DeepEqual(out.firsts(), want) {
+		t.Fatalf("chunks should be equal %v, current %v", want, out.firsts())
+	}
+
+	// More than 60 ms queued up behind 3, it is concealed.
+	for _, seq := range []uint32{7, 3, 5, 9} {
+		if err := b.Push(seq, chunk(seq)); err != nil {
+			t.Fatalf("push %d: %s", seq, err.Error())
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("close: %s", err.Error())
+	}
+	if err := b.Push(10, chunk(10)); err != jitter.ErrClosed {
+		t.Fatalf("push after close should fail with %v, current %v", jitter.ErrClosed, err)
+	}
+
+	if want := []int16{1, 2, 3, 0, 5, 6, 7, 8, 0, 10}; !reflect.DeepEqual(out.firsts(), want) {
+		t.Fatalf("chunks should be equal %v, current %v", want, out.firsts())
+	}
+	for i, c := range out.chunks {
+		if len(c) != 160 {
+			t.Fatalf("chunk %d length should be equal 160, current %d", i, len(c))
+		}
+	}
+
+	want := jitter.Stats{Delivered: 8, Lost: 2, Late: 1, Duplicate: 1}
+	if got := b.Stats(); got != want {
+		t.Fatalf("stats should be equal %+v, current %+v", want, got)
+	}
+}
+
+func TestBufferAdvance(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	out := &sink{}
+	cfg := jitter.Config{SampleRate: 8000, Channels: 1, MaxHold: 60 * time.Millisecond, Now: func() time.Time { return now }}
+	b := jitter.New(cfg, out)
+
+	for _, seq := range []uint32{0, 2, 3} {
+		if err := b.Push(seq, chunk(seq)); err != nil {
+			t.Fatalf("push %d: %s", seq, err.Error())
+		}
+		now = now.Add(10 * time.Millisecond)
+	}
+
+	// The client stalls: 2 and 3 are held until 2 has waited for 60 ms.
+	for _, tc := range []struct {
+		at   time.Duration
+		want []int16
+	}{
+		{50 * time.Millisecond, []int16{1}},
+		{70 * time.Millisecond, []int16{1}},
+		{71 * time.Millisecond, []int16{1, 0, 3, 4}},
+	} {
+		if err := b.Advance(start.Add(tc.at)); err != nil {
+			t.Fatalf("advance: %s", err.Error())
+		}
+		if !reflect.DeepEqual(out.firsts(), tc.want) {
+			t.Fatalf("chunks after %v should be equal %v, current %v", tc.at, tc.want, out.firsts())
+		}
+	}
+
+	want := jitter.Stats{Delivered: 3, Lost: 1}
+	if got := b.Stats(); got != want {
+		t.Fatalf("stats should be equal %+v, current %+v", want, got)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("close: %s", err.Error())
+	}
+	if err := b.Advance(now); err != jitter.ErrClosed {
+		t.Fatalf("advance after close should fail with %v, current %v", jitter.ErrClosed, err)
+	}
+}
+
+func TestBufferWraparound(t *testing.T) {
+	out := &sink{}
+	b := jitter.New(jitter.Config{SampleRate: 8000, Channels: 1}, out)
+
+	for _, seq := range []uint32{0xfffffffe, 0, 0xffffffff, 1} {
+		if err := b.Push(seq, chunk(seq)); err != nil {
+			t.Fatalf("push %d: %s", seq, err.Error())
+		}
+	}
+	want := []int16{int16(0xfffffffe%1000) + 1, int16(0xffffffff%1000) + 1, 1, 2}
+	if !reflect.DeepEqual(out.firsts(), want) {
+		t.Fatalf("chunks should be equal %v, current %v", want, out.firsts())
+	}
+
+	// A jump far ahead restarts the sequence instead of concealing the
+	// chunks in between.
+	if err := b.Push(100000, chunk(100000)); err != nil {
+		t.Fatalf("push: %s", err.Error())
+	}
+	if got := b.Stats(); got.Lost != 0 || got.Delivered != 5 {
+		t.Fatalf("stats should be 5 delivered and 0 lost, current %+v", got)
+	}
+}
+
+func TestBufferOpus(t *testing.T) {
+	cfg := opus.Config{SampleRate: 48000, NumChannels: 1, FrameSize: 20 * time.Millisecond}
+	enc, err := opus.NewEncoder(cfg)
+	if err != nil {
+		t.Fatalf("create encoder: %s", err.Error())
+	}
+	packets, _, err := enc.Encode(make([]int16, 5*960))
+	if err != nil {
+		t.Fatalf("encode: %s", err.Error())
+	}
+
+	out := &sink{}
+	b := jitter.New(jitter.Config{SampleRate: 48000, Channels: 1, MaxHold: 40 * time.Millisecond, Conceal: jitter.PLC}, out)
+	for _, seq := range []uint32{0, 2, 3, 4} {
+		if err := b.PushOpus(seq, packets[seq]); err != nil {
+			t.Fatalf("push %d: %s", seq, err.Error())
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("close: %s", err.Error())
+	}
+
+	if len(out.chunks) != 5 {
+		t.Fatalf("chunks should be equal 5, current %d", len(out.chunks))
+	}
+	for i, c := range out.chunks {
+		if len(c) != 960 {
+			t.Fatalf("chunk %d length should be equal 960, current %d", i, len(c))
+		}
+	}
+	if got := b.Stats(); got.Lost != 1 || got.Delivered != 4 {
+		t.Fatalf("stats should be 4 delivered and 1 lost, current %+v", got)
+	}
+
+	if err := b.Push(5, make([]int16, 960)); err != jitter.ErrMixed {
+		t.Fatalf("push of PCM should fail with %v, current %v", jitter.ErrMixed, err)
+	}
+}
+
+func TestBufferOpusCorrupt(t *testing.T) {
+	cfg := opus.Config{SampleRate: 48000, NumChannels: 1, FrameSize: 20 * time.Millisecond}
+	enc, err := opus.NewEncoder(cfg)
+	if err != nil {
+		t.Fatalf("create encoder: %s", err.Error())
+	}
+	packets, _, err := enc.Encode(make([]int16, 4*960))
+	if err != nil {
+		t.Fatalf("encode: %s", err.Error())
+	}
+	// The first packet is unreadable, so there is no chunk before it to
+	// take the length from, and the third one, two frames whose sizes do
+	// not add up, does not decode.
+	packets[0] = []byte{0xff}
+	packets[2] = []byte{packets[2][0]&^3 | 1, 0, 0, 0}
+
+	out := &sink{}
+	b := jitter.New(jitter.Config{SampleRate: 48000, Channels: 1, Conceal: jitter.PLC}, out)
+	for seq, packet := range packets {
+		if err := b.PushOpus(uint32(seq), packet); err != nil {
+			t.Fatalf("push %d: %s", seq, err.Error())
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("close: %s", err.Error())
+	}
+
+	lengths := []int{960, 960, 1920, 960}
+	if len(out.chunks) != len(lengths) {
+		t.Fatalf("chunks should be equal %d, current %d", len(lengths), len(out.chunks))
+	}
+	for i, c := range out.chunks {
+		if len(c) != lengths[i] {
+			t.Fatalf("chunk %d length should be equal %d, current %d", i, lengths[i], len(c))
+		}
+	}
+	want := jitter.Stats{Delivered: 2, Lost: 2}
+	if got := b.Stats(); got != want {
+		t.Fatalf("stats should be equal %+v, current %+v", want, got)
+	}
+}
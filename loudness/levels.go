@@ -0,0 +1,123 @@
+package loudness
+
+import "math"
+
+// SilenceThreshold is the RMS level in dBFS below which a frame of a
+// channel counts as silent.
+const SilenceThreshold = -60.0
+
+// Levels describes one channel of a frame of PCM. Levels are in dBFS
+// relative to a full scale square wave, so a full scale sine has a peak of
+// 0 dBFS and an RMS level of -3 dBFS; digital silence is -Inf.
+type Levels struct {
+	Peak float64
+	RMS  float64
+	// Clipped is the number of samples at the limits of the 16-bit range.
+	Clipped int
+	// Silent is set when RMS is below SilenceThreshold.
+	Silent bool
+}
+
+// LevelSummary describes everything a LevelMeter measured, all channels
+// together.
+type LevelSummary struct {
+	Peak float64 // dBFS
+	RMS  float64 // dBFS, the average level
+	// ClippedPercent is the share of samples at the limits of the 16-bit
+	// range.
+	ClippedPercent float64
+	// Samples is the number of samples per channel measured.
+	Samples int64
+}
+
+// LevelMeter measures peak and RMS levels and clipping frame by frame, for
+// live meters, and keeps totals for a summary.
+type LevelMeter struct {
+	channels int
+	levels   []Levels
+	peaks    []int
+	sums     []float64
+
+	peak    int
+	sum     float64 // sum of squares of all samples
+	clipped int64
+	samples int64 // per channel
+}
+
+// NewLevelMeter creates a meter for interleaved PCM with the given number
+// of channels.
+func NewLevelMeter(channels int) *LevelMeter {
+	return &LevelMeter{
+		channels: channels,
+		levels:   make([]Levels, channels),
+		peaks:    make([]int, channels),
+		sums:     make([]float64, channels),
+	}
+}
+
+// Frame measures a frame of interleaved samples and adds it to the totals.
+// The returned slice holds the levels per channel and is reused by the
+// next call.
+func (m *LevelMeter) Frame(pcm []int16) []Levels {
+	peaks := m.peaks
+	clear(peaks)
+	clear(m.sums)
+	for ch := range m.levels {
+		m.levels[ch] = Levels{}
+	}
+
+	n := len(pcm) / m.channels
+	for i := 0; i < n*m.channels; i += m.channels {
+		for ch := 0; ch < m.channels; ch++ {
+			v := pcm[i+ch]
+			peaks[ch] = max(peaks[ch], abs(v))
+			m.sums[ch] += float64(v) * float64(v)
+			if v == math.MaxInt16 || v == math.MinInt16 {
+				m.levels[ch].Clipped++
+			}
+		}
+	}
+
+	for ch := range m.levels {
+		l := &m.levels[ch]
+		l.Peak = dbfs(float64(peaks[ch]))
+		l.RMS = math.Inf(-1)
+		if n > 0 {
+			l.RMS = dbfs(math.Sqrt(m.sums[ch] / float64(n)))
+		}
+		l.Silent = l.RMS < SilenceThreshold
+
+		m.peak = max(m.peak, peaks[ch])
+		m.sum += m.sums[ch]
+		m.clipped += int64(l.Clipped)
+	}
+	m.samples += int64(n)
+
+	return m.levels
+}
+
+// Summary returns the totals of all frames measured so far.
+func (m *LevelMeter) Summary() LevelSummary {
+	s := LevelSummary{
+		Peak:    dbfs(float64(m.peak)),
+		RMS:     math.Inf(-1),
+		Samples: m.samples,
+	}
+	if total := m.samples * int64(m.channels); total > 0 {
+		s.RMS = dbfs(math.Sqrt(m.sum / float64(total)))
+		s.ClippedPercent = 100 * float64(m.clipped) / float64(total)
+	}
+	return s
+}
+
+func abs(v int16) int {
+	if v < 0 {
+		return -int(v)
+	}
+	return int(v)
+}
+
+// dbfs converts a sample magnitude to dBFS.
+func dbfs(v float64) float64 {
+	return 20 * math.Log10(v/32768)
+}
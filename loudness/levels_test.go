@@ -0,0 +1,52 @@
+package loudness_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/loudness"
+)
+
+func TestLevelMeter(t *testing.T) {
+	m := loudness.NewLevelMeter(2)
+
+	// Left a half scale sine, right silent.
+	pcm := sine(48000, 2, 1000, 0.5, 1)
+	for i := 1; i < len(pcm); i += 2 {
+		pcm[i] = 0
+	}
+	levels := m.Frame(pcm)
+	if got := levels[0].Peak; math.Abs(got+6.02) > 0.01 {
+		t.Fatalf("peak should be equal -6.02, current %.2f", got)
+	}
+	if got := levels[0].RMS; math.Abs(got+9.03) > 0.01 {
+		t.Fatalf("rms should be equal -9.03, current %.2f", got)
+	}
+	if levels[0].Silent || !levels[1].Silent {
+		t.Fatalf("silent should be equal [false true], current [%v %v]", levels[0].Silent, levels[1].Silent)
+	}
+	if !math.IsInf(levels[1].RMS, -1) {
+		t.Fatalf("rms of silence should be equal -Inf, current %.2f", levels[1].RMS)
+	}
+
+	// A clipped frame.
+	levels = m.Frame([]int16{32767, 0, -32768, 0, 0, 0, 0, 0})
+	if levels[0].Clipped != 2 || levels[1].Clipped != 0 {
+		t.Fatalf("clipped should be equal [2 0], current [%d %d]", levels[0].Clipped, levels[1].Clipped)
+	}
+
+	s := m.Summary()
+	if s.Samples != 48004 {
+		t.Fatalf("samples should be equal 48004, current %d", s.Samples)
+	}
+	if s.Peak != 0 {
+		t.Fatalf("peak should be equal 0, current %.2f", s.Peak)
+	}
+	if want := 100 * 2.0 / 96008; math.Abs(s.ClippedPercent-want) > 1e-9 {
+		t.Fatalf("clipped percent should be equal %f, current %f", want, s.ClippedPercent)
+	}
+	// The tone averaged over both channels.
+	if math.Abs(s.RMS+12.04) > 0.01 {
+		t.Fatalf("rms should be equal -12.04, current %.2f", s.RMS)
+	}
+}
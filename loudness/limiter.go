@@ -0,0 +1,167 @@
+package loudness
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	limiterLookahead = 5 * time.Millisecond
+	limiterRelease   = 50 * time.Millisecond
+	interpTaps       = 6 // taps on each side of the true-peak interpolator
+	oversampling     = 4
+)
+
+// interpolator holds the windowed sinc coefficients that estimate the
+// signal between two samples at 1/4, 2/4 and 3/4 of the interval, which
+// approximates the 4x oversampled true peak of BS.1770.
+var interpolator = func() [oversampling - 1][2 * interpTaps]float64 {
+	var c [oversampling - 1][2 * interpTaps]float64
+	for phase := range c {
+		frac := float64(phase+1) / oversampling
+		for i := range c[phase] {
+			x := frac - float64(i-interpTaps+1)
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/interpTaps)
+			c[phase][i] = window * math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+	}
+	return c
+}()
+
+// limiter keeps the true peak of interleaved float samples below a
+// ceiling. The gain needed for every sample is known limiterLookahead in
+// advance, so it is reached with a smooth ramp instead of clipping, and
+// recovers exponentially afterwards.
+type limiter struct {
+	channels  int
+	ceiling   float64 // linear
+	lookahead int     // frames
+	release   float64 // per frame recovery factor
+
+	in   []float64 // interleaved frames from index base on
+	base int
+	n    int // frames pushed, including the silence of flush
+	real int // frames pushed by push
+	done int // frames written to out
+
+	mins     []minEntry // increasing required gains of the lookahead window
+	envelope float64
+	ramp     []float64 // last lookahead envelope values
+	rampSum  float64
+
+	out []int16
+}
+
+type minEntry struct {
+	frame int
+	gain  float64
+}
+
+func newLimiter(sampleRate, channels int, ceiling float64) *limiter {
+	l := &limiter{
+		channels:  channels,
+		ceiling:   math.Pow(10, ceiling/20),
+		lookahead: max(int(limiterLookahead)*sampleRate/int(time.Second), 1),
+		release:   math.Exp(-float64(time.Second) / float64(limiterRelease) / float64(sampleRate)),
+		envelope:  1,
+	}
+	// Silence before the signal gives the interpolator its history.
+	l.in = make([]float64, interpTaps*channels)
+	l.base = -interpTaps
+	l.ramp = make([]float64, l.lookahead)
+	for i := range l.ramp {
+		l.ramp[i] = 1
+	}
+	l.rampSum = float64(l.lookahead)
+
+	return l
+}
+
+// push adds one frame of samples.
+func (l *limiter) push(frame []float64) {
+	l.real++
+	l.add(frame)
+}
+
+// flush pushes silence until every frame passed to push has been written
+// to out.
+func (l *limiter) flush() {
+	silence := make([]float64, l.channels)
+	for l.done < l.real {
+		l.add(silence)
+	}
+}
+
+func (l *limiter) add(frame []float64) {
+	l.in = append(l.in, frame...)
+	l.n++
+
+	// The interpolation after frame k needs interpTaps frames after it.
+	k := l.n - 1 - interpTaps
+	if k < 0 {
+		return
+	}
+
+	need := 1.0
+	if peak := l.peak(k); peak > l.ceiling {
+		need = l.ceiling / peak
+	}
+
+	for len(l.mins) > 0 && l.mins[len(l.mins)-1].gain >= need {
+		l.mins = l.mins[:len(l.mins)-1]
+	}
+	l.mins = append(l.mins, minEntry{frame: k, gain: need})
+	if l.mins[0].frame <= k-l.lookahead {
+		l.mins = l.mins[1:]
+	}
+
+	// Every value averaged for frame j is the minimum of a window that
+	// contains j, so the ramp never exceeds the gain that frame needs.
+	l.envelope = min(l.mins[0].gain, 1-(1-l.envelope)*l.release)
+	slot := k % l.lookahead
+	l.rampSum += l.envelope - l.ramp[slot]
+	l.ramp[slot] = l.envelope
+
+	j := k - l.lookahead + 1
+	if j < 0 || l.done == l.real {
+		return
+	}
+	g := min(l.rampSum/float64(l.lookahead), 1)
+	for _, v := range l.frame(j) {
+		l.out = append(l.out, toInt16(v*g))
+	}
+	l.done++
+
+	// Drop the frames no longer needed by the output and the interpolator
+	// once there are enough of them to make the copy worthwhile.
+	if drop := min(j+1, k-interpTaps+2) - l.base; drop >= l.lookahead+interpTaps {
+		l.in = append(l.in[:0], l.in[drop*l.channels:]...)
+		l.base += drop
+	}
+}
+
+// peak returns the largest absolute sample value of frame k and the
+// interpolated values between it and the next frame.
+func (l *limiter) peak(k int) float64 {
+	peak := 0.0
+	for ch := 0; ch < l.channels; ch++ {
+		peak = max(peak, math.Abs(l.frame(k)[ch]))
+		for _, c := range interpolator {
+			v := 0.0
+			for i, coef := range c {
+				v += coef * l.frame(k - interpTaps + 1 + i)[ch]
+			}
+			peak = max(peak, math.Abs(v))
+		}
+	}
+	return peak
+}
+
+func (l *limiter) frame(i int) []float64 {
+	off := (i - l.base) * l.channels
+	return l.in[off : off+l.channels]
+}
+
+func toInt16(v float64) int16 {
+	return int16(max(min(math.Round(v*32768), math.MaxInt16), math.MinInt16))
+}
@@ -0,0 +1,112 @@
+package loudness_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/loudness"
+)
+
+func TestMeter(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate int
+		channels   int
+		amplitude  float64
+		silence    int // seconds of silence before the tone
+		want       float64
+	}{
+		{
+			name:       "mono 48 kHz",
+			sampleRate: 48000,
+			channels:   1,
+			amplitude:  0.1,
+			want:       -23.01,
+		},
+		{
+			name:       "stereo 48 kHz",
+			sampleRate: 48000,
+			channels:   2,
+			amplitude:  0.1,
+			want:       -20.00,
+		},
+		{
+			name:       "mono 16 kHz",
+			sampleRate: 16000,
+			channels:   1,
+			amplitude:  0.1,
+			want:       -23.01,
+		},
+		{
+			name:       "silence is gated",
+			sampleRate: 48000,
+			channels:   1,
+			amplitude:  0.1,
+			silence:    5,
+			// Only the three blocks overlapping the start of the tone
+			// pass the gates besides the tone: 48.5/50 of its power.
+			want: -23.14,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := loudness.NewMeter(tt.sampleRate, tt.channels)
+			m.Write(make([]int16, tt.silence*tt.sampleRate*tt.channels))
+			m.Write(sine(tt.sampleRate, tt.channels, 997, tt.amplitude, 5))
+
+			if got := m.Integrated(); math.Abs(got-tt.want) > 0.05 {
+				t.Fatalf("loudness should be %.2f LUFS, current %.2f", tt.want, got)
+			}
+			if got, want := m.Gain(), loudness.Reference-tt.want; math.Abs(got-want) > 0.05 {
+				t.Fatalf("gain should be %.2f dB, current %.2f", want, got)
+			}
+		})
+	}
+}
+
+func TestMeterSilence(t *testing.T) {
+	m := loudness.NewMeter(48000, 1)
+	m.Write(make([]int16, 48000))
+
+	if got := m.Integrated(); !math.IsInf(got, -1) {
+		t.Fatalf("loudness of silence should be -Inf, current %.2f", got)
+	}
+	if got := m.Gain(); got != 0 {
+		t.Fatalf("gain of silence should be 0, current %.2f", got)
+	}
+}
+
+func TestAlbum(t *testing.T) {
+	loud := loudness.NewMeter(48000, 1)
+	loud.Write(sine(48000, 1, 997, 0.1, 5))
+	quiet := loudness.NewMeter(48000, 1)
+	quiet.Write(sine(48000, 1, 997, 0.01, 5))
+
+	var album loudness.Album
+	album.Add(loud)
+	album.Add(quiet)
+
+	// Equal durations at -23 and -43 LUFS; the quiet track is gated out.
+	if got := album.Integrated(); math.Abs(got+23.01) > 0.05 {
+		t.Fatalf("album loudness should be -23.01 LUFS, current %.2f", got)
+	}
+
+	if got := loudness.Q78(-1.5); got != -384 {
+		t.Fatalf("Q7.8 of -1.5 dB should be -384, current %d", got)
+	}
+	if got := loudness.Q78(200); got != math.MaxInt16 {
+		t.Fatalf("Q7.8 should be clamped, current %d", got)
+	}
+}
+
+func sine(sampleRate, channels int, freq, amplitude float64, seconds int) []int16 {
+	pcm := make([]int16, seconds*sampleRate*channels)
+	for i := 0; i < seconds*sampleRate; i++ {
+		v := int16(math.Round(amplitude * 32767 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))))
+		for ch := 0; ch < channels; ch++ {
+			pcm[i*channels+ch] = v
+		}
+	}
+	return pcm
+}
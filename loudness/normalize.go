@@ -0,0 +1,182 @@
+package loudness
+
+import "math"
+
+const (
+	// MaxGain limits the gain applied by normalization in both directions,
+	// so near-silent recordings are not turned into amplified noise.
+	MaxGain = 20.0 // dB
+
+	shortTermSteps = 30  // 3 s short-term loudness window
+	centerSteps    = 15  // steps of the window after the step it is used for
+	gainSlew       = 0.5 // dB the streaming gain may change per step
+)
+
+// Normalize brings interleaved PCM to the target integrated loudness in
+// LUFS with a constant gain, limited to MaxGain, and keeps its true peak
+// below ceiling in dBTP. It is the two-pass counterpart of Normalizer for
+// audio that is available as a whole.
+func Normalize(pcm []int16, sampleRate, channels int, target, ceiling float64) []int16 {
+	m := NewMeter(sampleRate, channels)
+	m.Write(pcm)
+	g := math.Pow(10, clampGain(gain(target, m.Integrated()))/20)
+
+	l := newLimiter(sampleRate, channels, ceiling)
+	l.out = make([]int16, 0, len(pcm))
+	frame := make([]float64, channels)
+	for i := 0; i+channels <= len(pcm); i += channels {
+		for ch := range frame {
+			frame[ch] = float64(pcm[i+ch]) / 32768 * g
+		}
+		l.push(frame)
+	}
+	l.flush()
+
+	return l.out
+}
+
+// Normalizer brings a stream of interleaved PCM to a target loudness
+// while it passes through. The gain follows the short-term (3 s) loudness
+// around each 100 ms step, so the output is delayed by 1.5 s plus a few
+// milliseconds for the true peak limiter. Quiet passages well below the
+// loudness measured so far keep the previous gain instead of being
+// pumped up.
+type Normalizer struct {
+	channels int
+	step     int // samples per channel in 100 ms
+	target   float64
+	meter    *Meter
+	limiter  *limiter
+
+	powers  []float64 // step powers of the short-term window
+	first   int       // step of powers[0]
+	steps   int       // steps measured
+	emitted int       // steps passed on to the limiter
+	gatedP  float64   // sum of the step powers above the absolute gate
+	gatedN  int
+
+	pending []float64 // input of the steps not yet emitted
+	gain    float64   // dB
+	linear  float64   // gain at the end of the last emitted step
+	started bool
+}
+
+// NewNormalizer creates a normalizer for PCM with the given sample rate
+// and number of channels. Target is the loudness in LUFS, ceiling the
+// maximum true peak in dBTP.
+func NewNormalizer(sampleRate, channels int, target, ceiling float64) *Normalizer {
+	n := &Normalizer{
+		channels: channels,
+		step:     sampleRate / 10,
+		target:   target,
+		meter:    NewMeter(sampleRate, channels),
+		limiter:  newLimiter(sampleRate, channels, ceiling),
+	}
+	n.meter.stepped = n.measured
+
+	return n
+}
+
+// Process adds interleaved samples and returns the normalized samples that
+// are ready. The result is only valid until the next call.
+func (n *Normalizer) Process(pcm []int16) []int16 {
+	n.limiter.out = n.limiter.out[:0]
+	for _, v := range pcm[:len(pcm)/n.channels*n.channels] {
+		n.pending = append(n.pending, float64(v)/32768)
+	}
+	n.meter.Write(pcm)
+
+	return n.limiter.out
+}
+
+// Flush returns the remaining normalized samples. Afterwards the output
+// has exactly as many samples as the input.
+func (n *Normalizer) Flush() []int16 {
+	n.limiter.out = n.limiter.out[:0]
+
+	// The last partial step takes part in the window of the steps before.
+	if m := n.meter; m.pos > 0 {
+		power := 0.0
+		for _, s := range m.sums {
+			power += s / float64(m.pos)
+		}
+		n.powers = append(n.powers, power)
+	}
+	for len(n.pending) > 0 {
+		n.emit(min(n.step*n.channels, len(n.pending)))
+	}
+	n.limiter.flush()
+
+	return n.limiter.out
+}
+
+// measured receives the power of every complete step from the meter.
+func (n *Normalizer) measured(power float64) {
+	n.powers = append(n.powers, power)
+	if len(n.powers) > shortTermSteps {
+		n.powers = n.powers[1:]
+		n.first++
+	}
+	n.steps++
+	if loudnessOf(power) > absoluteGate {
+		n.gatedP += power
+		n.gatedN++
+	}
+
+	if n.steps > centerSteps {
+		n.emit(n.step * n.channels)
+	}
+}
+
+// emit decides the gain of the oldest pending step and passes its samples
+// on to the limiter, ramping from the gain of the step before.
+func (n *Normalizer) emit(samples int) {
+	// The window reaches centerSteps past the step, or up to the end of
+	// the input when flushing.
+	c := n.emitted
+	lo := max(c-(shortTermSteps-centerSteps-1), n.first) - n.first
+	hi := min(c+centerSteps+1, n.first+len(n.powers)) - n.first
+	n.adjust(n.powers[lo:hi])
+
+	target := math.Pow(10, n.gain/20)
+	frames := samples / n.channels
+	frame := make([]float64, n.channels)
+	for i := 0; i < frames; i++ {
+		g := n.linear + (target-n.linear)*float64(i+1)/float64(frames)
+		for ch := range frame {
+			frame[ch] = n.pending[i*n.channels+ch] * g
+		}
+		n.limiter.push(frame)
+	}
+	n.linear = target
+	n.pending = n.pending[samples:]
+	n.emitted++
+}
+
+// adjust moves the gain towards the one that brings the loudness of the
+// window to the target.
+func (n *Normalizer) adjust(window []float64) {
+	sum := 0.0
+	for _, p := range window {
+		sum += p
+	}
+	shortTerm := loudnessOf(sum / float64(len(window)))
+
+	want := n.gain
+	if n.gatedN > 0 && shortTerm > absoluteGate &&
+		shortTerm > loudnessOf(n.gatedP/float64(n.gatedN))+relativeGate {
+		want = clampGain(n.target - shortTerm)
+	}
+
+	if !n.started {
+		n.gain = want
+		n.linear = math.Pow(10, want/20)
+		n.started = true
+		return
+	}
+	n.gain += max(min(want-n.gain, gainSlew), -gainSlew)
+}
+
+func clampGain(g float64) float64 {
+	return max(min(g, MaxGain), -MaxGain)
+}
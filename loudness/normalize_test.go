@@ -0,0 +1,86 @@
+package loudness_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/loudness"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		amplitude float64
+		target    float64
+		want      float64
+	}{
+		{name: "boost", amplitude: 0.05, target: -16, want: -16},
+		{name: "cut", amplitude: 0.5, target: -23, want: -23},
+		{name: "boost is limited", amplitude: 0.001, target: -16, want: -60.00 + loudness.MaxGain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcm := sine(48000, 2, 997, tt.amplitude, 5)
+			out := loudness.Normalize(pcm, 48000, 2, tt.target, -1)
+
+			if len(out) != len(pcm) {
+				t.Fatalf("output length should be equal %d, current %d", len(pcm), len(out))
+			}
+			if got := integrated(out, 48000, 2); math.Abs(got-tt.want) > 0.1 {
+				t.Fatalf("loudness should be %.2f LUFS, current %.2f", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeCeiling(t *testing.T) {
+	pcm := sine(48000, 1, 997, 0.5, 2)
+	out := loudness.Normalize(pcm, 48000, 1, -6, -3)
+
+	ceiling := math.Pow(10, -3.0/20) * 32768
+	if got := peak(out); got > ceiling+1 {
+		t.Fatalf("peak should be at most %.0f, current %.0f", ceiling, got)
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	quiet := sine(16000, 1, 440, 0.05, 10)
+	loud := sine(16000, 1, 440, 0.4, 10)
+	pcm := append(quiet, loud...)
+
+	n := loudness.NewNormalizer(16000, 1, -16, -1)
+	var out []int16
+	for i := 0; i < len(pcm); i += 1234 {
+		out = append(out, n.Process(pcm[i:min(i+1234, len(pcm))])...)
+	}
+	out = append(out, n.Flush()...)
+
+	if len(out) != len(pcm) {
+		t.Fatalf("output length should be equal %d, current %d", len(pcm), len(out))
+	}
+	// Both halves end up at the target once the gain has settled; the
+	// window around the change sees both levels.
+	for _, part := range [][]int16{out[4*16000 : 8*16000], out[15*16000:]} {
+		if got := integrated(part, 16000, 1); math.Abs(got+16) > 0.5 {
+			t.Fatalf("loudness should be -16.00 LUFS, current %.2f", got)
+		}
+	}
+	if got, ceiling := peak(out), math.Pow(10, -1.0/20)*32768; got > ceiling+1 {
+		t.Fatalf("peak should be at most %.0f, current %.0f", ceiling, got)
+	}
+}
+
+func integrated(pcm []int16, sampleRate, channels int) float64 {
+	m := loudness.NewMeter(sampleRate, channels)
+	m.Write(pcm)
+	return m.Integrated()
+}
+
+func peak(pcm []int16) float64 {
+	p := 0.0
+	for _, v := range pcm {
+		p = max(p, math.Abs(float64(v)))
+	}
+	return p
+}
@@ -0,0 +1,259 @@
+// Package mix combines several timestamped PCM streams, such as the
+// participants of a call, into one stream for a packer.
+package mix
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	ErrChannels = errors.New("mix: only mono and stereo are supported")
+	ErrClosed   = errors.New("mix: source is closed")
+)
+
+// Sink receives the mixed PCM. packer.Packer, packer.Rotator and
+// packer.Splitter are sinks.
+type Sink interface {
+	SendPCMChunk(chunk []int16) error
+}
+
+// Config describes the mixed output.
+type Config struct {
+	SampleRate int
+	// Channels is 1 or 2.
+	Channels int
+	// Wait bounds how far the mix may lag behind the source that is
+	// furthest ahead. Sources that have not delivered audio for that long
+	// are mixed as silence, and what they deliver later for that time is
+	// dropped. Zero mixes only what all open sources have delivered.
+	Wait time.Duration
+}
+
+// Mixer aligns the sources on a common timeline starting at 0 and writes
+// the mix to a sink as soon as it is complete. It is safe for concurrent
+// use, so every source can be written from its own goroutine.
+type Mixer struct {
+	mu      sync.Mutex
+	cfg     Config
+	wait    int64 // samples per channel
+	sink    Sink
+	sources []*Source
+	pos     int64 // samples per channel mixed
+	buf     []float64
+	out     []int16
+}
+
+// Source is one input of a Mixer.
+type Source struct {
+	m        *Mixer
+	channels int
+	gain     float64 // linear
+	pan      float64 // -1 left to 1 right
+	closed   bool
+
+	base int64     // position of the first sample in data
+	data []float64 // interleaved in the source channel layout
+	late int64     // samples per channel dropped
+}
+
+// SourceOption configures a Source.
+type SourceOption func(*Source)
+
+// WithGain changes the level of a source by db decibels.
+func WithGain(db float64) SourceOption {
+	return func(s *Source) {
+		s.gain = math.Pow(10, db/20)
+	}
+}
+
+// WithPan places a mono source between -1 (left) and 1 (right) in a
+// stereo mix with constant power. For stereo sources it sets the balance
+// by attenuating the other side. Mono mixes ignore it.
+func WithPan(pan float64) SourceOption {
+	return func(s *Source) {
+		s.pan = max(min(pan, 1), -1)
+	}
+}
+
+// New returns a Mixer that writes to sink.
+func New(cfg Config, sink Sink) (*Mixer, error) {
+	if cfg.Channels != 1 && cfg.Channels != 2 {
+		return nil, ErrChannels
+	}
+
+	return &Mixer{
+		cfg:  cfg,
+		wait: int64(cfg.Wait) * int64(cfg.SampleRate) / int64(time.Second),
+		sink: sink,
+	}, nil
+}
+
+// AddSource adds an input with the given number of channels and the
+// sample rate of the mix. Its audio starts at the current mix position at
+// the earliest.
+func (m *Mixer) AddSource(channels int, opts ...SourceOption) (*Source, error) {
+	if channels != 1 && channels != 2 {
+		return nil, ErrChannels
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := &Source{m: m, channels: channels, gain: 1, base: m.pos}
+	for _, opt := range opts {
+		opt(s)
+	}
+	m.sources = append(m.sources, s)
+
+	return s, nil
+}
+
+// Write adds interleaved samples that start at ts on the timeline of the
+// mix. A gap to the previous samples is filled with silence; samples for
+// time that is already mixed or already delivered are dropped and
+// counted by Late.
+func (s *Source) Write(ts time.Duration, pcm []int16) error {
+	m := s.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	start := int64(ts) * int64(m.cfg.SampleRate) / int64(time.Second)
+	frames := int64(len(pcm) / s.channels)
+	if skip := min(max(s.end()-start, 0), frames); skip > 0 {
+		s.late += skip
+		pcm = pcm[skip*int64(s.channels):]
+		start += skip
+	}
+	for gap := start - s.end(); gap > 0; gap-- {
+		for ch := 0; ch < s.channels; ch++ {
+			s.data = append(s.data, 0)
+		}
+	}
+	for _, v := range pcm[:len(pcm)/s.channels*s.channels] {
+		s.data = append(s.data, float64(v)*s.gain)
+	}
+
+	return m.mix(false)
+}
+
+// Close ends a source. The mix no longer waits for it.
+func (s *Source) Close() error {
+	m := s.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s.closed = true
+	return m.mix(false)
+}
+
+// Late returns the number of samples per channel that were dropped
+// because they arrived after their time was mixed or delivered twice.
+func (s *Source) Late() int64 {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.late
+}
+
+// Close mixes everything the sources have delivered. The sink is not
+// closed.
+func (m *Mixer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mix(true)
+}
+
+// Position returns the end of the mixed audio on the timeline.
+func (m *Mixer) Position() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return time.Duration(m.pos) * time.Second / time.Duration(m.cfg.SampleRate)
+}
+
+// end returns the position after the last delivered sample.
+func (s *Source) end() int64 {
+	return max(s.base+int64(len(s.data)/s.channels), s.m.pos)
+}
+
+// mix writes the audio up to the point all sources have delivered, or
+// that the wait bound allows, to the sink.
+func (m *Mixer) mix(all bool) error {
+	ready, ahead := int64(math.MaxInt64), m.pos
+	for _, s := range m.sources {
+		ahead = max(ahead, s.end())
+		if !s.closed {
+			ready = min(ready, s.end())
+		}
+	}
+	if all || ready == math.MaxInt64 {
+		ready = ahead
+	}
+	if m.wait > 0 {
+		ready = max(ready, ahead-m.wait)
+	}
+	if ready <= m.pos {
+		return nil
+	}
+
+	n := int(ready - m.pos)
+	m.buf = append(m.buf[:0], make([]float64, n*m.cfg.Channels)...)
+	for _, s := range m.sources {
+		s.mixInto(m.buf, n)
+	}
+
+	m.out = m.out[:0]
+	for _, v := range m.buf {
+		m.out = append(m.out, int16(max(min(math.Round(v), math.MaxInt16), math.MinInt16)))
+	}
+	m.pos = ready
+
+	return m.sink.SendPCMChunk(m.out)
+}
+
+// mixInto adds the next n samples per channel of the source to buf and
+// drops them.
+func (s *Source) mixInto(buf []float64, n int) {
+	m := s.m
+	off := int(m.pos - s.base)
+	avail := max(min(len(s.data)/s.channels-off, n), 0)
+
+	left, right := panGains(s.pan)
+	if s.channels == 2 {
+		left, right = min(1-s.pan, 1), min(1+s.pan, 1)
+	}
+	for i := 0; i < avail; i++ {
+		frame := s.data[(off+i)*s.channels : (off+i+1)*s.channels]
+		switch {
+		case m.cfg.Channels == 1:
+			sum := 0.0
+			for _, v := range frame {
+				sum += v
+			}
+			buf[i] += sum / float64(s.channels)
+		case s.channels == 1:
+			buf[2*i] += frame[0] * left
+			buf[2*i+1] += frame[0] * right
+		default:
+			buf[2*i] += frame[0] * left
+			buf[2*i+1] += frame[1] * right
+		}
+	}
+
+	// Whatever was not delivered in time is silence from now on.
+	consumed := min(off+n, len(s.data)/s.channels)
+	s.data = append(s.data[:0], s.data[consumed*s.channels:]...)
+	s.base = m.pos + int64(n)
+}
+
+// panGains returns the constant power gains of a mono source at a
+// position between -1 and 1, both 1/sqrt(2) in the center.
+func panGains(pan float64) (float64, float64) {
+	angle := (pan + 1) * math.Pi / 4
+	return math.Cos(angle), math.Sin(angle)
+}
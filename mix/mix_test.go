@@ -0,0 +1,156 @@
+package mix_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/mix"
+)
+
+type sink struct {
+	pcm    []int16
+	chunks int
+}
+
+func (s *sink) SendPCMChunk(chunk []int16) error {
+	s.pcm = append(s.pcm, chunk...)
+	s.chunks++
+	return nil
+}
+
+func constant(n int, v int16) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		pcm[i] = v
+	}
+	return pcm
+}
+
+func TestMixerAlignsSources(t *testing.T) {
+	out := &sink{}
+	m, err := mix.New(mix.Config{SampleRate: 1000, Channels: 1}, out)
+	if err != nil {
+		t.Fatalf("cannot create mixer: %v", err)
+	}
+	a, _ := m.AddSource(1)
+	b, _ := m.AddSource(1)
+
+	// Nothing is mixed until both sources delivered.
+	if err := a.Write(0, constant(100, 1000)); err != nil {
+		t.Fatalf("cannot write: %v", err)
+	}
+	if len(out.pcm) != 0 {
+		t.Fatalf("mixed samples should be equal %d, current %d", 0, len(out.pcm))
+	}
+
+	// b starts 50 ms late, the gap is silence.
+	if err := b.Write(50*time.Millisecond, constant(100, 500)); err != nil {
+		t.Fatalf("cannot write: %v", err)
+	}
+	if len(out.pcm) != 100 {
+		t.Fatalf("mixed samples should be equal %d, current %d", 100, len(out.pcm))
+	}
+	if out.pcm[10] != 1000 || out.pcm[60] != 1500 {
+		t.Fatalf("mixed samples should be equal 1000 and 1500, current %d and %d", out.pcm[10], out.pcm[60])
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("cannot close mixer: %v", err)
+	}
+	if len(out.pcm) != 150 {
+		t.Fatalf("mixed samples should be equal %d, current %d", 150, len(out.pcm))
+	}
+	if out.pcm[120] != 500 {
+		t.Fatalf("mixed sample should be equal %d, current %d", 500, out.pcm[120])
+	}
+	if got := m.Position(); got != 150*time.Millisecond {
+		t.Fatalf("position should be equal %s, current %s", 150*time.Millisecond, got)
+	}
+}
+
+func TestMixerWait(t *testing.T) {
+	out := &sink{}
+	m, _ := mix.New(mix.Config{SampleRate: 1000, Channels: 1, Wait: 100 * time.Millisecond}, out)
+	a, _ := m.AddSource(1)
+	b, _ := m.AddSource(1)
+
+	// b is silent, so the mix runs at most 100 ms behind a.
+	for i := 0; i < 5; i++ {
+		_ = a.Write(time.Duration(i)*100*time.Millisecond, constant(100, 1000))
+	}
+	if len(out.pcm) != 400 {
+		t.Fatalf("mixed samples should be equal %d, current %d", 400, len(out.pcm))
+	}
+
+	// What b delivers for mixed time is dropped.
+	_ = b.Write(350*time.Millisecond, constant(100, 500))
+	if got := b.Late(); got != 50 {
+		t.Fatalf("late samples should be equal %d, current %d", 50, got)
+	}
+	_ = m.Close()
+	if len(out.pcm) != 500 {
+		t.Fatalf("mixed samples should be equal %d, current %d", 500, len(out.pcm))
+	}
+	if out.pcm[390] != 1000 || out.pcm[420] != 1500 {
+		t.Fatalf("mixed samples should be equal 1000 and 1500, current %d and %d", out.pcm[390], out.pcm[420])
+	}
+
+	// A closed source is no longer waited for.
+	_ = a.Close()
+	if err := a.Write(time.Second, constant(10, 1)); err != mix.ErrClosed {
+		t.Fatalf("write after close should fail with %v, current %v", mix.ErrClosed, err)
+	}
+}
+
+func TestMixerClosedSource(t *testing.T) {
+	out := &sink{}
+	m, _ := mix.New(mix.Config{SampleRate: 1000, Channels: 1}, out)
+	a, _ := m.AddSource(1)
+	b, _ := m.AddSource(1)
+
+	_ = a.Write(0, constant(100, 1000))
+	_ = b.Close()
+	if len(out.pcm) != 100 {
+		t.Fatalf("mixed samples should be equal %d, current %d", 100, len(out.pcm))
+	}
+}
+
+func TestMixerGainAndPan(t *testing.T) {
+	out := &sink{}
+	m, _ := mix.New(mix.Config{SampleRate: 1000, Channels: 2}, out)
+	left, _ := m.AddSource(1, mix.WithPan(-1))
+	center, _ := m.AddSource(1, mix.WithGain(-6))
+	stereo, _ := m.AddSource(2)
+
+	_ = left.Write(0, constant(10, 1000))
+	_ = center.Write(0, constant(10, 2000))
+	_ = stereo.Write(0, []int16{100, 200, 100, 200, 100, 200, 100, 200, 100, 200, 100, 200, 100, 200, 100, 200, 100, 200, 100, 200})
+
+	if len(out.pcm) != 20 {
+		t.Fatalf("mixed samples should be equal %d, current %d", 20, len(out.pcm))
+	}
+	c := 2000 * math.Pow(10, -6.0/20) / math.Sqrt2
+	wantL := int16(math.Round(1000 + c + 100))
+	wantR := int16(math.Round(c + 200))
+	if out.pcm[0] != wantL || out.pcm[1] != wantR {
+		t.Fatalf("mixed frame should be equal [%d %d], current [%d %d]", wantL, wantR, out.pcm[0], out.pcm[1])
+	}
+}
+
+func TestMixerClips(t *testing.T) {
+	out := &sink{}
+	m, _ := mix.New(mix.Config{SampleRate: 1000, Channels: 1}, out)
+	a, _ := m.AddSource(1)
+	b, _ := m.AddSource(1)
+
+	_ = a.Write(0, []int16{30000, -30000})
+	_ = b.Write(0, []int16{30000, -30000})
+	if out.pcm[0] != math.MaxInt16 || out.pcm[1] != math.MinInt16 {
+		t.Fatalf("mixed samples should be equal [%d %d], current %v", math.MaxInt16, math.MinInt16, out.pcm)
+	}
+
+	if _, err := m.AddSource(3); err != mix.ErrChannels {
+		t.Fatalf("adding a source should fail with %v, current %v", mix.ErrChannels, err)
+	}
+}
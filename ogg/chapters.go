@@ -0,0 +1,118 @@
+package ogg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBadChapter is returned for chapter comments with a malformed time.
+var ErrBadChapter = errors.New("ogg: malformed chapter comment")
+
+// Chapter is a chapter marker stored as CHAPTERxxx and CHAPTERxxxNAME
+// comments, following the Vorbis comment chapter extension.
+type Chapter struct {
+	Start time.Duration
+	Name  string
+}
+
+// SetChapters replaces all chapter comments with chapters, numbered from
+// CHAPTER001 in the given order.
+func (t *Tags) SetChapters(chapters []Chapter) {
+	comments := t.Comments[:0]
+	for _, c := range t.Comments {
+		if k, _, _ := strings.Cut(c, "="); chapterNumber(k) < 0 {
+			comments = append(comments, c)
+		}
+	}
+	t.Comments = comments
+
+	for i, c := range chapters {
+		key := fmt.Sprintf("CHAPTER%03d", i+1)
+		t.Add(key, FormatTimestamp(c.Start))
+		t.Add(key+"NAME", c.Name)
+	}
+}
+
+// Chapters returns the chapters in t ordered by their number.
+func (t Tags) Chapters() ([]Chapter, error) {
+	starts := map[int]time.Duration{}
+	names := map[int]string{}
+	for _, c := range t.Comments {
+		k, v, _ := strings.Cut(c, "=")
+		n := chapterNumber(k)
+		switch {
+		case n < 0:
+		case strings.HasSuffix(strings.ToUpper(k), "NAME"):
+			names[n] = v
+		default:
+			d, err := ParseTimestamp(v)
+			if err != nil {
+				return nil, ErrBadChapter
+			}
+			starts[n] = d
+		}
+	}
+
+	numbers := make([]int, 0, len(starts))
+	for n := range starts {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	chapters := make([]Chapter, len(numbers))
+	for i, n := range numbers {
+		chapters[i] = Chapter{Start: starts[n], Name: names[n]}
+	}
+	return chapters, nil
+}
+
+// chapterNumber returns the number of a CHAPTERxxx or CHAPTERxxxNAME key,
+// or -1 for other keys.
+func chapterNumber(key string) int {
+	key = strings.ToUpper(key)
+	if !strings.HasPrefix(key, "CHAPTER") {
+		return -1
+	}
+	digits := strings.TrimSuffix(key[len("CHAPTER"):], "NAME")
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 0 || digits == "" || digits[0] == '+' || digits[0] == '-' {
+		return -1
+	}
+	return n
+}
+
+// FormatTimestamp formats d as HH:MM:SS.mmm, the form used by the DURATION
+// and chapter comments.
+func FormatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// ParseTimestamp parses a time in HH:MM:SS.sss form, with any number of
+// fractional digits.
+func ParseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("timestamp %q is not in HH:MM:SS.sss form", s)
+	}
+
+	h, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse hours of %q: %w", s, err)
+	}
+	m, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil || m > 59 {
+		return 0, fmt.Errorf("invalid minutes in %q", s)
+	}
+	sec, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil || sec < 0 || sec >= 60 || strings.ContainsAny(parts[2], "eE+-") {
+		return 0, fmt.Errorf("invalid seconds in %q", s)
+	}
+
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(sec*float64(time.Second)+0.5), nil
+}
@@ -0,0 +1,48 @@
+package ogg_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+func TestTagsChapters(t *testing.T) {
+	tags := ogg.Tags{Comments: []string{
+		"TITLE=Weekly sync",
+		"CHAPTER002=00:05:12.5",
+		"CHAPTER002NAME=Budget",
+		"chapter001=00:00:00.000",
+		"CHAPTER001NAME=Welcome",
+	}}
+
+	chapters, err := tags.Chapters()
+	if err != nil {
+		t.Fatalf("parse chapters: %s", err.Error())
+	}
+	want := []ogg.Chapter{
+		{Start: 0, Name: "Welcome"},
+		{Start: 5*time.Minute + 12500*time.Millisecond, Name: "Budget"},
+	}
+	if !reflect.DeepEqual(chapters, want) {
+		t.Fatalf("chapters should be equal %v, current %v", want, chapters)
+	}
+
+	want = append(want, ogg.Chapter{Start: time.Hour + 2*time.Second, Name: "Q&A"})
+	tags.SetChapters(want)
+	wantComments := []string{
+		"TITLE=Weekly sync",
+		"CHAPTER001=00:00:00.000", "CHAPTER001NAME=Welcome",
+		"CHAPTER002=00:05:12.500", "CHAPTER002NAME=Budget",
+		"CHAPTER003=01:00:02.000", "CHAPTER003NAME=Q&A",
+	}
+	if !reflect.DeepEqual(tags.Comments, wantComments) {
+		t.Fatalf("comments should be equal %v, current %v", wantComments, tags.Comments)
+	}
+
+	tags.Add("CHAPTER004", "5 minutes")
+	if _, err := tags.Chapters(); err != ogg.ErrBadChapter {
+		t.Fatalf("parse error should be %v, current %v", ogg.ErrBadChapter, err)
+	}
+}
@@ -0,0 +1,86 @@
+package ogg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrBadSync is returned when a page does not start with the "OggS" capture pattern.
+	ErrBadSync = errors.New("ogg: bad page capture pattern")
+	// ErrBadVersion is returned for pages with an unknown stream structure version.
+	ErrBadVersion = errors.New("ogg: unsupported stream structure version")
+	// ErrBadCrc is returned when the checksum of a page does not match its content.
+	ErrBadCrc = errors.New("ogg: page checksum mismatch")
+)
+
+// A Page is a single page of an ogg stream.
+type Page struct {
+	// Type is a bitmask of COP, BOS and EOS.
+	Type byte
+	// Serial is the serial number of the logical stream the page belongs to.
+	Serial uint32
+	// Sequence is the number of the page within its logical stream.
+	Sequence uint32
+	// Granule is the codec-specific position of the last packet that ends
+	// on the page, or -1 if no packet ends on it.
+	Granule int64
+	// Packets holds the packets in the page. If Type has COP set, the first
+	// one is the rest of a packet started on a previous page; if Partial is
+	// set, the last one continues on the next page.
+	Packets [][]byte
+	Partial bool
+	// Size is the size of the whole page in bytes.
+	Size int
+	// Data is the whole page as read.
+	Data []byte
+}
+
+// A Decoder reads the pages of an ogg stream.
+type Decoder struct {
+	r   io.Reader
+	hdr [headsz + mss]byte
+}
+
+// NewDecoder creates an ogg decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the next page. It returns io.EOF when the stream ends at a
+// page boundary and io.ErrUnexpectedEOF when it ends within a page.
+func (d *Decoder) Decode() (Page, error) {
+	hdr := d.hdr[:headsz]
+	if _, err := io.ReadFull(d.r, hdr); err != nil {
+		return Page{}, err
+	}
+	if !bytes.Equal(hdr[:4], []byte("OggS")) {
+		return Page{}, ErrBadSync
+	}
+
+	segtbl := d.hdr[headsz : headsz+int(hdr[26])]
+	if _, err := io.ReadFull(d.r, segtbl); err != nil {
+		return Page{}, unexpected(err)
+	}
+
+	size := 0
+	for _, s := range segtbl {
+		size += int(s)
+	}
+
+	page := make([]byte, headsz+len(segtbl)+size)
+	copy(page, d.hdr[:headsz+len(segtbl)])
+	if _, err := io.ReadFull(d.r, page[headsz+len(segtbl):]); err != nil {
+		return Page{}, unexpected(err)
+	}
+
+	return ParsePage(page)
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package ogg
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEncoder(7, &b)
+
+	long := bytes.Repeat([]byte{'x'}, mps+10)
+	if err := e.EncodeBOS(0, [][]byte{[]byte("head")}); err != nil {
+		t.Fatal("unexpected EncodeBOS error:", err)
+	}
+	if err := e.Encode(960, [][]byte{[]byte("hello"), long}); err != nil {
+		t.Fatal("unexpected Encode error:", err)
+	}
+	if err := e.EncodeEOS(1920, nil); err != nil {
+		t.Fatal("unexpected EncodeEOS error:", err)
+	}
+
+	d := NewDecoder(bytes.NewReader(b.Bytes()))
+
+	expect := []struct {
+		kind     byte
+		granule  int64
+		packets  [][]byte
+		partial  bool
+		sequence uint32
+	}{
+		{BOS, 0, [][]byte{[]byte("head")}, false, 0},
+		{0, 960, [][]byte{[]byte("hello"), long[:mps-mss]}, true, 1},
+		{COP, 960, [][]byte{long[mps-mss:]}, false, 2},
+		{EOS, 1920, [][]byte{{}}, false, 3},
+	}
+
+	size := 0
+	for i, want := range expect {
+		page, err := d.Decode()
+		if err != nil {
+			t.Fatalf("page %d: unexpected Decode error: %s", i, err)
+		}
+		size += page.Size
+
+		if page.Type != want.kind || page.Granule != want.granule || page.Serial != 7 ||
+			page.Sequence != want.sequence || page.Partial != want.partial {
+			t.Fatalf("page %d: header != expected: %+v", i, page)
+		}
+		if len(page.Packets) != len(want.packets) {
+			t.Fatalf("page %d: %d packets, expected %d", i, len(page.Packets), len(want.packets))
+		}
+		for j := range want.packets {
+			if !bytes.Equal(page.Packets[j], want.packets[j]) {
+				t.Fatalf("page %d: packet %d != expected", i, j)
+			}
+		}
+	}
+
+	if size != b.Len() {
+		t.Fatalf("page sizes add up to %d, expected %d", size, b.Len())
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Fatal("expected EOF, got:", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEncoder(1, &b)
+	if err := e.Encode(2, [][]byte{[]byte("hello")}); err != nil {
+		t.Fatal("unexpected Encode error:", err)
+	}
+	page := b.Bytes()
+
+	corrupt := append([]byte(nil), page...)
+	corrupt[len(corrupt)-1] = 'O'
+	if _, err := NewDecoder(bytes.NewReader(corrupt)).Decode(); err != ErrBadCrc {
+		t.Fatal("expected ErrBadCrc, got:", err)
+	}
+
+	if _, err := NewDecoder(bytes.NewReader(page[:len(page)-1])).Decode(); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected ErrUnexpectedEOF, got:", err)
+	}
+
+	if _, err := NewDecoder(bytes.NewReader(page[1:])).Decode(); err != ErrBadSync {
+		t.Fatal("expected ErrBadSync, got:", err)
+	}
+}
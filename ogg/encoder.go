@@ -97,8 +97,15 @@ func (w *Encoder) writePage(h *pageHeader, segtbl []byte, pay payload) error {
 	h.Page = w.page
 	w.page++
 	h.Nsegs = byte(len(segtbl))
+
+	// A page on which no packet ends has no granule position (RFC 3533, 6).
+	ph := *h
+	if !endsPacket(segtbl) {
+		ph.Granule = -1
+	}
+
 	hb := bytes.NewBuffer(w.buf[0:0:cap(w.buf)])
-	_ = binary.Write(hb, byteOrder, h)
+	_ = binary.Write(hb, byteOrder, &ph)
 
 	// segtbl is already written in the buffer,
 	// but the writer needs to move along anyhow
@@ -118,6 +125,17 @@ func (w *Encoder) writePage(h *pageHeader, segtbl []byte, pay payload) error {
 	return err
 }
 
+// endsPacket reports whether a packet ends on a page with the segment table
+// segtbl, which is the case if any lacing value is below the maximum.
+func endsPacket(segtbl []byte) bool {
+	for _, s := range segtbl {
+		if s < mss {
+			return true
+		}
+	}
+	return false
+}
+
 // payload represents a potentially-split group of packets.
 // For the "left" portion of a split,
 // rightover is the beginning portion of the *last* packet,
@@ -191,10 +191,11 @@ func TestLongEncode(t *testing.T) {
 		'O', 'g', 'g', 'S',
 		0,
 		0,
-		2, 0, 0, 0, 0, 0, 0, 0,
+		// no packet ends on the page, so it has no granule position
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
 		1, 0, 0, 0,
 		0, 0, 0, 0,
-		0xee, 0xb2, 0x0b, 0xca, // crc
+		0xf6, 0x57, 0x1d, 0x19, // crc
 		255,
 	}
 
@@ -206,10 +207,10 @@ func TestLongEncode(t *testing.T) {
 		'O', 'g', 'g', 'S',
 		0,
 		COP,
-		2, 0, 0, 0, 0, 0, 0, 0,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
 		1, 0, 0, 0,
 		1, 0, 0, 0,
-		0x17, 0x0d, 0xe6, 0xe6, // crc
+		0x0f, 0xe8, 0xf0, 0x35, // crc
 		255,
 	}
 
@@ -118,7 +118,11 @@ var crcTable = [256]uint32{
 
 // "unreflected" crc used by libogg
 func crc32(p []byte) uint32 {
-	c := uint32(0)
+	return crc32Update(0, p)
+}
+
+// crc32Update continues the crc c with the bytes in p.
+func crc32Update(c uint32, p []byte) uint32 {
 	for _, n := range p {
 		c = crcTable[byte(c>>24)^n] ^ (c << 8)
 	}
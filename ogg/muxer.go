@@ -0,0 +1,222 @@
+package ogg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+var (
+	// ErrMuxStarted is returned when a stream is added after the muxer has
+	// written its first page.
+	ErrMuxStarted = errors.New("ogg: muxer has already started writing")
+	// ErrDuplicateSerial is returned when two streams share a serial number.
+	ErrDuplicateSerial = errors.New("ogg: duplicate stream serial number")
+)
+
+// A Muxer groups several logical streams into one ogg file as described in
+// RFC 3533, section 4: the BOS pages of all streams come first, followed by
+// the remaining header pages and the data pages of all streams interleaved
+// in the order of their granule-derived time.
+//
+// Streams are either ogg Packers added with AddPacker or arbitrary
+// Encoders writing into a writer obtained from NewStream. All streams have
+// to be added before the first call to Flush.
+type Muxer struct {
+	w       io.Writer
+	streams []*muxStream
+	started bool
+}
+
+type muxStream struct {
+	packer      *Packer
+	granuleRate int64
+	serial      uint32
+	pages       []muxPage
+	partial     []byte // incomplete page written by an encoder
+	lastGranule int64
+	eos         bool
+	err         error // the encoder wrote something that is not a page
+}
+
+type muxPage struct {
+	data    []byte
+	granule int64
+	bos     bool
+}
+
+// NewMuxer creates a muxer writing the grouped stream to w.
+func NewMuxer(w io.Writer) *Muxer {
+	return &Muxer{w: w}
+}
+
+// AddPacker adds the logical stream produced by p. The muxer takes over
+// reading pages from p, so ReadPages must not be used on it any more.
+func (m *Muxer) AddPacker(p *Packer) error {
+	s, err := m.addStream(GranuleRate)
+	if err != nil {
+		return err
+	}
+	s.packer = p
+	s.serial = p.Serial()
+
+	return nil
+}
+
+// NewStream adds a logical stream and returns the writer an Encoder for it
+// has to write into. The granule positions of the stream count granuleRate
+// units per second.
+func (m *Muxer) NewStream(granuleRate int) (io.Writer, error) {
+	return m.addStream(int64(granuleRate))
+}
+
+func (m *Muxer) addStream(granuleRate int64) (*muxStream, error) {
+	if m.started {
+		return nil, ErrMuxStarted
+	}
+
+	s := &muxStream{granuleRate: granuleRate}
+	m.streams = append(m.streams, s)
+
+	return s, nil
+}
+
+// Flush writes all pages whose position in the output is already known,
+// that is every page not later than the earliest pending page of every
+// stream that has not ended yet.
+func (m *Muxer) Flush() error {
+	return m.flush(false)
+}
+
+// Close writes all remaining pages. The streams should have written their
+// EOS pages before.
+func (m *Muxer) Close() error {
+	return m.flush(true)
+}
+
+func (m *Muxer) flush(final bool) error {
+	for _, s := range m.streams {
+		if s.packer != nil {
+			if _, err := s.packer.WritePagesTo(s); err != nil {
+				return fmt.Errorf("read pages of stream %d: %w", s.serial, err)
+			}
+		}
+	}
+
+	if !m.started {
+		if err := m.writeBOS(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		next := -1
+		for i, s := range m.streams {
+			if len(s.pages) == 0 {
+				if !s.eos && !final {
+					// The stream may still produce an earlier page.
+					return nil
+				}
+				continue
+			}
+			if next < 0 || s.before(m.streams[next]) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+
+		s := m.streams[next]
+		if _, err := m.w.Write(s.pages[0].data); err != nil {
+			return fmt.Errorf("write page: %w", err)
+		}
+		s.pages = s.pages[1:]
+	}
+}
+
+// writeBOS writes the first page of every stream, which has to be its BOS
+// page.
+func (m *Muxer) writeBOS() error {
+	serials := make(map[uint32]bool, len(m.streams))
+	for i, s := range m.streams {
+		if len(s.pages) == 0 || !s.pages[0].bos {
+			return fmt.Errorf("stream %d has not written its BOS page", i)
+		}
+		if serials[s.serial] {
+			return ErrDuplicateSerial
+		}
+		serials[s.serial] = true
+	}
+
+	m.started = true
+	for _, s := range m.streams {
+		if _, err := m.w.Write(s.pages[0].data); err != nil {
+			return fmt.Errorf("write BOS page: %w", err)
+		}
+		s.pages = s.pages[1:]
+	}
+
+	return nil
+}
+
+// Write queues the complete pages in b. Pages may be split across calls.
+// Once b does not continue with a page, the pages before it are still
+// queued, but the stream fails all further writes.
+func (s *muxStream) Write(b []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n := len(b)
+	buffered := len(s.partial)
+	if buffered > 0 {
+		b = append(s.partial, b...)
+		s.partial = nil
+	}
+
+	for off := 0; len(b) > 0; {
+		page, err := ParsePage(b)
+		if err == io.ErrUnexpectedEOF {
+			s.partial = append([]byte(nil), b...)
+			break
+		}
+		if err != nil {
+			// Only the bytes of this call up to the bad page were
+			// consumed. The buffered start of a page stays buffered.
+			if off < buffered {
+				s.partial = append([]byte(nil), b[:buffered-off]...)
+			}
+			s.err = err
+			return max(off-buffered, 0), err
+		}
+
+		// Pages on which no packet ends carry no time of their own.
+		granule := page.Granule
+		if granule < 0 {
+			granule = s.lastGranule
+		}
+		s.lastGranule = granule
+		s.serial = page.Serial
+		s.eos = page.Type&EOS != 0
+
+		s.pages = append(s.pages, muxPage{
+			data:    append([]byte(nil), b[:page.Size]...),
+			granule: granule,
+			bos:     page.Type&BOS != 0,
+		})
+		b = b[page.Size:]
+		off += page.Size
+	}
+
+	return n, nil
+}
+
+// before reports whether the next page of s comes earlier in time than the
+// next page of o.
+func (s *muxStream) before(o *muxStream) bool {
+	// Compare granule/rate exactly, the products can exceed 64 bits.
+	ahi, alo := bits.Mul64(uint64(max(s.pages[0].granule, 0)), uint64(o.granuleRate))
+	bhi, blo := bits.Mul64(uint64(max(o.pages[0].granule, 0)), uint64(s.granuleRate))
+	return ahi < bhi || ahi == bhi && alo < blo
+}
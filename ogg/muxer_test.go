@@ -0,0 +1,116 @@
+package ogg_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+func TestMuxer(t *testing.T) {
+	var out bytes.Buffer
+	muxer := ogg.NewMuxer(&out)
+
+	rawOpusData := rawOpusPackets(t, fmt.Sprintf("testdata/opus_raw/%s.opus_raw", fileBasePath))
+
+	var packers []*ogg.Packer
+	for i := 0; i < 2; i++ {
+		packer, err := ogg.New(1, 48000, ogg.WithSerial(uint32(100+i)))
+		if err != nil {
+			t.Fatalf("create ogg packer: %s", err.Error())
+		}
+		if err := muxer.AddPacker(packer); err != nil {
+			t.Fatalf("add packer to muxer: %s", err.Error())
+		}
+		packers = append(packers, packer)
+	}
+
+	// The second participant sends packets half as often, each twice as long.
+	for i, packet := range rawOpusData[:20] {
+		if err := packers[0].AddChunk(packet, i == 19, 2880); err != nil {
+			t.Fatalf("send opus chunk to packer: %s", err.Error())
+		}
+		if i%2 == 0 {
+			if err := packers[1].AddChunk(packet, i == 18, 5760); err != nil {
+				t.Fatalf("send opus chunk to packer: %s", err.Error())
+			}
+		}
+		if err := muxer.Flush(); err != nil {
+			t.Fatalf("flush muxer: %s", err.Error())
+		}
+	}
+
+	if err := muxer.AddPacker(packers[0]); err != ogg.ErrMuxStarted {
+		t.Fatalf("adding a stream after start should fail with %v, current %v", ogg.ErrMuxStarted, err)
+	}
+	if err := muxer.Close(); err != nil {
+		t.Fatalf("close muxer: %s", err.Error())
+	}
+
+	decoder := ogg.NewDecoder(&out)
+	var pages []ogg.Page
+	for {
+		page, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decode page: %s", err.Error())
+		}
+		pages = append(pages, page)
+	}
+
+	if len(pages) != 2*2+20+10 {
+		t.Fatalf("pages count should be equal %d, current %d", 2*2+20+10, len(pages))
+	}
+	if pages[0].Type&ogg.BOS == 0 || pages[1].Type&ogg.BOS == 0 || pages[0].Serial == pages[1].Serial {
+		t.Fatal("file should start with the BOS pages of both streams")
+	}
+
+	var last int64
+	for i, page := range pages[2:] {
+		if page.Type&ogg.BOS != 0 {
+			t.Fatalf("page %d: unexpected BOS page", i+2)
+		}
+		if page.Granule < last {
+			t.Fatalf("page %d: granule %d is earlier than previous page %d", i+2, page.Granule, last)
+		}
+		last = page.Granule
+	}
+}
+
+func TestMuxerStreamError(t *testing.T) {
+	var out bytes.Buffer
+	muxer := ogg.NewMuxer(&out)
+	w, err := muxer.NewStream(48000)
+	if err != nil {
+		t.Fatalf("create stream: %s", err.Error())
+	}
+
+	var page bytes.Buffer
+	if err := ogg.NewEncoder(7, &page).EncodeBOS(0, [][]byte{[]byte("head")}); err != nil {
+		t.Fatalf("encode BOS page: %s", err.Error())
+	}
+
+	if n, err := w.Write(page.Bytes()[:10]); n != 10 || err != nil {
+		t.Fatalf("write start of page: %d, %v", n, err)
+	}
+	// Something that is not a page, with an empty segment table.
+	garbage := append([]byte("xxxx"), make([]byte, 23)...)
+	rest := append(page.Bytes()[10:], garbage...)
+	if n, err := w.Write(rest); n != page.Len()-10 || err != ogg.ErrBadSync {
+		t.Fatalf("write should consume %d bytes and fail with %v, current %d, %v", page.Len()-10, ogg.ErrBadSync, n, err)
+	}
+	if n, err := w.Write(page.Bytes()); n != 0 || err != ogg.ErrBadSync {
+		t.Fatalf("write after an error should fail with %v, current %d, %v", ogg.ErrBadSync, n, err)
+	}
+
+	if err := muxer.Close(); err != nil {
+		t.Fatalf("close muxer: %s", err.Error())
+	}
+	if !bytes.Equal(out.Bytes(), page.Bytes()) {
+		t.Fatal("the page before the error should be written")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"runtime"
 	"time"
 
@@ -15,20 +16,70 @@ const (
 	serialNo       = 99999 // const for testing similarity in active development phase. Should be `rand.New(rand.NewSource(time.Now().UTC().Unix() % 0x80000000)).Int31()` in real world
 	initBufferSize = 4096
 	maxFrameSize   = 5760
+	// GranuleRate is the rate of Ogg Opus granule positions, which count
+	// samples at 48 kHz whatever the input sample rate (RFC 7845, 4).
+	GranuleRate = 48000
 )
 
+// ErrHeaderOverflow is returned when rewritten header pages do not fit into
+// the space taken by the header pages written at creation time.
+var ErrHeaderOverflow = errors.New("rewritten header does not fit into reserved space")
+
 type Packer struct {
+	serial       uint32
 	channelCount uint8
 	sampleRate   uint32
+	preSkip      uint16
+	outputGain   int16
 	packetNo     int64
 	granulePos   int64
 	buffer       bytes.Buffer
 	oggEncoder   *Encoder
 	opusDecoder  *opus.Decoder
+	tags         *Tags
+	tagsSize     int
+	headerSize   int
+}
+
+// Option configures optional behaviour of an ogg Packer.
+type Option func(*Packer)
+
+// WithTags makes the packer write a complete OpusTags comment header built
+// from t instead of the minimal empty one. Padding in t reserves space that
+// PatchedHeaderPages can later fill with final comments.
+func WithTags(t Tags) Option {
+	return func(p *Packer) {
+		p.tags = &t
+	}
+}
+
+// WithSerial sets the serial number of the logical stream.
+func WithSerial(serial uint32) Option {
+	return func(p *Packer) {
+		p.serial = serial
+	}
+}
+
+// WithPreSkip sets the number of 48 kHz samples (per channel) a decoder has
+// to discard from the start of the decoded output, which is usually the
+// lookahead of the encoder.
+func WithPreSkip(samples uint16) Option {
+	return func(p *Packer) {
+		p.preSkip = samples
+	}
+}
+
+// WithOutputGain sets the gain in Q7.8 dB a decoder applies to the decoded
+// output.
+func WithOutputGain(gain int16) Option {
+	return func(p *Packer) {
+		p.outputGain = gain
+	}
 }
 
-func New(channelCount uint8, sampleRate uint32) (*Packer, error) {
+func New(channelCount uint8, sampleRate uint32, opts ...Option) (*Packer, error) {
 	p := Packer{
+		serial:       serialNo,
 		channelCount: channelCount,
 		sampleRate:   sampleRate,
 		packetNo:     1,
@@ -38,6 +89,10 @@ func New(channelCount uint8, sampleRate uint32) (*Packer, error) {
 		opusDecoder:  nil,
 	}
 
+	for _, opt := range opts {
+		opt(&p)
+	}
+
 	if err := p.init(); err != nil {
 		return nil, fmt.Errorf("init ogg packer: %w", err)
 	}
@@ -50,6 +105,16 @@ func New(channelCount uint8, sampleRate uint32) (*Packer, error) {
 func (p *Packer) AddChunk(data []byte, eos bool, samplesCount int) error {
 	var numSamplesPerChannel int
 	if samplesCount < 0 {
+		if p.opusDecoder == nil {
+			// The input sample rate of copied streams need not be one
+			// the decoder supports, so it is only created when needed.
+			d, err := opus.NewDecoder(int(p.sampleRate), int(p.channelCount))
+			if err != nil {
+				return fmt.Errorf("create opus decoder: %w", err)
+			}
+			p.opusDecoder = d
+		}
+
 		var err error
 		buf := make([]int16, maxFrameSize*int16(p.channelCount))
 
@@ -61,7 +126,7 @@ func (p *Packer) AddChunk(data []byte, eos bool, samplesCount int) error {
 		numSamplesPerChannel = int(samplesCount) / int(p.channelCount)
 	}
 
-	p.granulePos += int64(numSamplesPerChannel)
+	p.granulePos += p.Granules(numSamplesPerChannel)
 
 	if err := p.sendPacketToOggStream(data, false, eos); err != nil {
 		return fmt.Errorf("send header data to ogg stream: %w", err)
@@ -70,6 +135,43 @@ func (p *Packer) AddChunk(data []byte, eos bool, samplesCount int) error {
 	return nil
 }
 
+// AddChunkWithGranule works like AddChunk, but sets the granule position of
+// the page to granulePos instead of deriving it from the packet. This is
+// used to trim padding from the end of the final packet and to signal gaps
+// in the stream.
+func (p *Packer) AddChunkWithGranule(data []byte, eos bool, granulePos int64) error {
+	p.granulePos = granulePos
+
+	if err := p.sendPacketToOggStream(data, false, eos); err != nil {
+		return fmt.Errorf("send data to ogg stream: %w", err)
+	}
+
+	return nil
+}
+
+// AddGap advances the granule position by a number of samples per channel
+// at the input sample rate without adding a packet. The next page then
+// starts after a gap, which decoders fill with silence.
+func (p *Packer) AddGap(samples int) {
+	p.granulePos += p.Granules(samples)
+}
+
+// Serial returns the serial number of the logical stream.
+func (p *Packer) Serial() uint32 {
+	return p.serial
+}
+
+// Granules converts a number of samples per channel at the input sample
+// rate into granule position units.
+func (p *Packer) Granules(samples int) int64 {
+	return int64(samples) * GranuleRate / int64(p.sampleRate)
+}
+
+// GranulePos returns the granule position of the last page.
+func (p *Packer) GranulePos() int64 {
+	return p.granulePos
+}
+
 func (p *Packer) ReadPages() ([]byte, error) {
 	b := p.buffer.Bytes()
 	if len(b) == 0 {
@@ -79,14 +181,60 @@ func (p *Packer) ReadPages() ([]byte, error) {
 	return b, nil
 }
 
-func (p *Packer) init() error {
-	p.oggEncoder = NewEncoder(serialNo, &p.buffer)
-
-	d, err := opus.NewDecoder(int(p.sampleRate), int(p.channelCount))
+// WritePagesTo writes all pages produced so far to w and empties the
+// internal buffer. Unlike ReadPages it is not an error if there is nothing
+// to write.
+func (p *Packer) WritePagesTo(w io.Writer) (int64, error) {
+	n, err := p.buffer.WriteTo(w)
 	if err != nil {
-		return fmt.Errorf("create opus decoder: %w", err)
+		return n, fmt.Errorf("write pages: %w", err)
+	}
+	return n, nil
+}
+
+// HeaderPages renders the OpusHead and OpusTags pages again with t as the
+// comment header.
+func (p *Packer) HeaderPages(t Tags) ([]byte, error) {
+	var b bytes.Buffer
+	e := NewEncoder(p.serial, &b)
+	if err := e.EncodeBOS(0, [][]byte{header(p.channelCount, p.sampleRate, p.preSkip, p.outputGain)}); err != nil {
+		return nil, fmt.Errorf("encode header page: %w", err)
+	}
+	if err := e.Encode(0, [][]byte{t.Marshal()}); err != nil {
+		return nil, fmt.Errorf("encode tags page: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// PatchedHeaderPages works like HeaderPages, but the result has exactly the
+// size of the header pages written by New, so it can overwrite them in
+// place; the padding of t is adjusted to make up the difference.
+// ErrHeaderOverflow is returned when t needs more space than was reserved.
+func (p *Packer) PatchedHeaderPages(t Tags) ([]byte, error) {
+	t.Padding = 0
+	t.Padding = p.tagsSize - len(t.Marshal())
+	if t.Padding < 0 {
+		return nil, ErrHeaderOverflow
 	}
-	p.opusDecoder = d
+
+	return p.HeaderPages(t)
+}
+
+// SetOutputGain changes the output gain in Q7.8 dB of the header pages
+// rendered by HeaderPages and PatchedHeaderPages. The pages already
+// written by New keep the gain they were created with.
+func (p *Packer) SetOutputGain(gain int16) {
+	p.outputGain = gain
+}
+
+// HeaderSize returns the size in bytes of the header pages written by New.
+func (p *Packer) HeaderSize() int {
+	return p.headerSize
+}
+
+func (p *Packer) init() error {
+	p.oggEncoder = NewEncoder(p.serial, &p.buffer)
 
 	if err := p.addHeader(); err != nil {
 		return fmt.Errorf("add header to ogg stream: %w", err)
@@ -95,12 +243,13 @@ func (p *Packer) init() error {
 	if err := p.addTags(); err != nil {
 		return fmt.Errorf("add tags packet: %w", err)
 	}
+	p.headerSize = p.buffer.Len()
 
 	return nil
 }
 
 func (p *Packer) addHeader() error {
-	header := header(p.channelCount, p.sampleRate)
+	header := header(p.channelCount, p.sampleRate, p.preSkip, p.outputGain)
 	if err := p.sendPacketToOggStream(header, true, false); err != nil {
 		return fmt.Errorf("send header data to ogg stream: %w", err)
 	}
@@ -111,6 +260,10 @@ func (p *Packer) addHeader() error {
 func (p *Packer) addTags() error {
 	tags := make([]byte, 9)
 	copy(tags, []byte("OpusTags"))
+	if p.tags != nil {
+		tags = p.tags.Marshal()
+	}
+	p.tagsSize = len(tags)
 
 	if err := p.sendPacketToOggStream(tags, false, false); err != nil {
 		return fmt.Errorf("send header data to ogg stream: %w", err)
@@ -151,16 +304,16 @@ func (p *Packer) sendPacketToOggStream(data []byte, bos bool, eos bool) error {
 	return nil
 }
 
-func header(channelCount uint8, sampleRate uint32) []byte {
+func header(channelCount uint8, sampleRate uint32, preSkip uint16, outputGain int16) []byte {
 	header := make([]byte, 19)
 	copy(header, []byte("OpusHead"))
 
 	header[8] = 1 // version number
 	header[9] = channelCount
 
-	binary.LittleEndian.PutUint16(header[10:12], 0)
+	binary.LittleEndian.PutUint16(header[10:12], preSkip)
 	binary.LittleEndian.PutUint32(header[12:16], sampleRate)
-	binary.LittleEndian.PutUint16(header[16:18], 0)
+	binary.LittleEndian.PutUint16(header[16:18], uint16(outputGain))
 
 	header[18] = 0
 
@@ -202,12 +355,11 @@ func CreateSkeletonTrack(d time.Duration) []byte {
 	return b
 }
 
-// Duration returns the duration of the audio accumulated in the packer.
+// Duration returns the duration of the audio accumulated in the packer,
+// not counting the pre-skip.
 func (p *Packer) Duration() time.Duration {
-	if p.sampleRate == 0 {
-		return 0
-	}
-	return time.Duration(p.granulePos) * time.Second / time.Duration(p.sampleRate)
+	samples := max(p.granulePos-int64(p.preSkip), 0)
+	return time.Duration(samples) * time.Second / GranuleRate
 }
 
 // AddSkeleton creates a skeleton packet for the provided duration and
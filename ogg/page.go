@@ -0,0 +1,104 @@
+package ogg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// ParsePage decodes the page at the start of b. The packets of the result
+// refer to b.
+func ParsePage(b []byte) (Page, error) {
+	size := pageSize(b)
+	if size == 0 {
+		return Page{}, io.ErrUnexpectedEOF
+	}
+	if !bytes.Equal(b[:4], []byte("OggS")) {
+		return Page{}, ErrBadSync
+	}
+	if b[4] != 0 {
+		return Page{}, ErrBadVersion
+	}
+
+	b = b[:size]
+	segtbl := b[headsz : headsz+int(b[26])]
+
+	crc := byteOrder.Uint32(b[22:26])
+	var zero [4]byte
+	if crc32Update(crc32Update(crc32(b[:22]), zero[:]), b[26:]) != crc {
+		return Page{}, ErrBadCrc
+	}
+
+	p := Page{
+		Type:     b[5],
+		Granule:  int64(binary.LittleEndian.Uint64(b[6:14])),
+		Serial:   byteOrder.Uint32(b[14:18]),
+		Sequence: byteOrder.Uint32(b[18:22]),
+		Size:     size,
+		Data:     b,
+	}
+
+	body := b[headsz+len(segtbl):]
+	start, end := 0, 0
+	for _, s := range segtbl {
+		end += int(s)
+		if s < mss {
+			p.Packets = append(p.Packets, body[start:end])
+			start = end
+		}
+	}
+	if len(segtbl) > 0 && segtbl[len(segtbl)-1] == mss {
+		p.Packets = append(p.Packets, body[start:end])
+		p.Partial = true
+	}
+
+	return p, nil
+}
+
+// SetSequence changes the sequence number of the page b to sequence and
+// updates its checksum.
+func SetSequence(b []byte, sequence uint32) {
+	byteOrder.PutUint32(b[18:22], sequence)
+	byteOrder.PutUint32(b[22:26], 0)
+	byteOrder.PutUint32(b[22:26], crc32(b))
+}
+
+// CountPages returns the number of complete pages at the start of b.
+func CountPages(b []byte) int {
+	n := 0
+	for size := pageSize(b); size > 0; size = pageSize(b) {
+		b = b[size:]
+		n++
+	}
+	return n
+}
+
+// RenumberPages adds delta to the sequence numbers of the pages in b and
+// updates their checksums.
+func RenumberPages(b []byte, delta uint32) error {
+	for len(b) > 0 {
+		size := pageSize(b)
+		if size == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		SetSequence(b[:size], byteOrder.Uint32(b[18:22])+delta)
+		b = b[size:]
+	}
+	return nil
+}
+
+// pageSize returns the size of the page at the start of b, or 0 if b is
+// too short to hold all of it.
+func pageSize(b []byte) int {
+	if len(b) < headsz || len(b) < headsz+int(b[26]) {
+		return 0
+	}
+	size := headsz + int(b[26])
+	for _, s := range b[headsz:size] {
+		size += int(s)
+	}
+	if len(b) < size {
+		return 0
+	}
+	return size
+}
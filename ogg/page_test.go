@@ -0,0 +1,46 @@
+package ogg
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRenumberPages(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEncoder(7, &b)
+	if err := e.EncodeBOS(0, [][]byte{[]byte("head")}); err != nil {
+		t.Fatal("unexpected EncodeBOS error:", err)
+	}
+	if err := e.Encode(960, [][]byte{bytes.Repeat([]byte{'x'}, mps+10)}); err != nil {
+		t.Fatal("unexpected Encode error:", err)
+	}
+	pages := b.Bytes()
+
+	if n := CountPages(pages); n != 3 {
+		t.Fatalf("%d pages, expected 3", n)
+	}
+	if n := CountPages(pages[:len(pages)-1]); n != 2 {
+		t.Fatalf("%d complete pages, expected 2", n)
+	}
+
+	if err := RenumberPages(pages, 5); err != nil {
+		t.Fatal("unexpected RenumberPages error:", err)
+	}
+	for i, off := uint32(0), 0; off < len(pages); i++ {
+		page := pages[off : off+pageSize(pages[off:])]
+		if seq := byteOrder.Uint32(page[18:22]); seq != 5+i {
+			t.Fatalf("page %d: sequence %d, expected %d", i, seq, 5+i)
+		}
+		crc := byteOrder.Uint32(page[22:26])
+		SetSequence(page, 5+i)
+		if byteOrder.Uint32(page[22:26]) != crc {
+			t.Fatalf("page %d: checksum not updated", i)
+		}
+		off += len(page)
+	}
+
+	if err := RenumberPages(pages[:len(pages)-1], 1); err != io.ErrUnexpectedEOF {
+		t.Fatal("expected ErrUnexpectedEOF, got:", err)
+	}
+}
@@ -0,0 +1,167 @@
+package ogg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/jpeg" // decode JPEG dimensions in AddPicture
+	_ "image/png"  // decode PNG dimensions in AddPicture
+)
+
+// PictureKey is the comment key under which pictures are stored.
+const PictureKey = "METADATA_BLOCK_PICTURE"
+
+// Picture types as defined by the ID3v2 APIC frame, a subset of the most
+// common ones.
+const (
+	PictureOther      = 0
+	PictureFileIcon   = 1
+	PictureFrontCover = 3
+	PictureBackCover  = 4
+	PictureArtist     = 8
+)
+
+// ErrBadPicture is returned for malformed picture blocks.
+var ErrBadPicture = errors.New("ogg: malformed picture block")
+
+// Picture is an image embedded in the comment header as a FLAC picture
+// metadata block.
+type Picture struct {
+	// Type is one of the Picture* constants.
+	Type        uint32
+	MIME        string
+	Description string
+	// Width, Height, Depth in bits per pixel and Colors of indexed images
+	// describe the image.
+	Width, Height, Depth, Colors uint32
+	Data                         []byte
+}
+
+// AddPicture stores p base64-encoded under METADATA_BLOCK_PICTURE. The
+// width, height and depth of JPEG and PNG images are filled in from the
+// image data if Width and Height are not set, and so is a missing MIME type.
+// Large pictures can make the comment header span several pages, which
+// the packer handles.
+func (t *Tags) AddPicture(p Picture) error {
+	if p.Width == 0 && p.Height == 0 {
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(p.Data))
+		if err != nil {
+			return ErrBadPicture
+		}
+		p.Width = uint32(cfg.Width)
+		p.Height = uint32(cfg.Height)
+		p.Depth, p.Colors = pictureDepth(cfg.ColorModel)
+		if p.MIME == "" {
+			p.MIME = "image/" + format
+		}
+	}
+
+	t.Add(PictureKey, base64.StdEncoding.EncodeToString(p.Marshal()))
+
+	return nil
+}
+
+// Pictures decodes all pictures stored under METADATA_BLOCK_PICTURE.
+func (t Tags) Pictures() ([]Picture, error) {
+	var pictures []Picture
+	for _, v := range t.Get(PictureKey) {
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, ErrBadPicture
+		}
+		p, err := ParsePicture(b)
+		if err != nil {
+			return nil, err
+		}
+		pictures = append(pictures, p)
+	}
+	return pictures, nil
+}
+
+// Marshal encodes p as a FLAC picture metadata block without the metadata
+// block header.
+func (p Picture) Marshal() []byte {
+	b := make([]byte, 0, 32+len(p.MIME)+len(p.Description)+len(p.Data))
+	b = binary.BigEndian.AppendUint32(b, p.Type)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.MIME)))
+	b = append(b, p.MIME...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.Description)))
+	b = append(b, p.Description...)
+	b = binary.BigEndian.AppendUint32(b, p.Width)
+	b = binary.BigEndian.AppendUint32(b, p.Height)
+	b = binary.BigEndian.AppendUint32(b, p.Depth)
+	b = binary.BigEndian.AppendUint32(b, p.Colors)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(p.Data)))
+	return append(b, p.Data...)
+}
+
+// ParsePicture decodes a FLAC picture metadata block.
+func ParsePicture(b []byte) (Picture, error) {
+	var p Picture
+	var ok bool
+
+	if p.Type, b, ok = pictureUint32(b); !ok {
+		return Picture{}, ErrBadPicture
+	}
+	var mime, desc []byte
+	if mime, b, ok = pictureBytes(b); !ok {
+		return Picture{}, ErrBadPicture
+	}
+	if desc, b, ok = pictureBytes(b); !ok {
+		return Picture{}, ErrBadPicture
+	}
+	p.MIME = string(mime)
+	p.Description = string(desc)
+
+	for _, v := range []*uint32{&p.Width, &p.Height, &p.Depth, &p.Colors} {
+		if *v, b, ok = pictureUint32(b); !ok {
+			return Picture{}, ErrBadPicture
+		}
+	}
+
+	if p.Data, _, ok = pictureBytes(b); !ok {
+		return Picture{}, ErrBadPicture
+	}
+
+	return p, nil
+}
+
+// pictureDepth returns the bits per pixel of images with color model m, and
+// the number of colors if they are indexed.
+func pictureDepth(m color.Model) (uint32, uint32) {
+	if p, ok := m.(color.Palette); ok {
+		return 8, uint32(len(p))
+	}
+
+	switch m {
+	case color.GrayModel:
+		return 8, 0
+	case color.Gray16Model:
+		return 16, 0
+	case color.YCbCrModel:
+		return 24, 0
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 64, 0
+	default:
+		return 32, 0
+	}
+}
+
+func pictureUint32(b []byte) (uint32, []byte, bool) {
+	if len(b) < 4 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint32(b), b[4:], true
+}
+
+// pictureBytes reads a length-prefixed byte string.
+func pictureBytes(b []byte) ([]byte, []byte, bool) {
+	n, b, ok := pictureUint32(b)
+	if !ok || uint64(n) > uint64(len(b)) {
+		return nil, nil, false
+	}
+	return b[:n], b[n:], true
+}
@@ -0,0 +1,57 @@
+package ogg_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+func TestTagsPictures(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+	img.Set(3, 4, color.NRGBA{R: 255, A: 255})
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		t.Fatalf("encode png: %s", err.Error())
+	}
+
+	var tags ogg.Tags
+	tags.Add("TITLE", "Episode 1")
+	err := tags.AddPicture(ogg.Picture{
+		Type:        ogg.PictureFrontCover,
+		Description: "cover",
+		Data:        data.Bytes(),
+	})
+	if err != nil {
+		t.Fatalf("add picture: %s", err.Error())
+	}
+	if err := tags.AddPicture(ogg.Picture{Data: []byte("not an image")}); err != ogg.ErrBadPicture {
+		t.Fatalf("add picture error should be %v, current %v", ogg.ErrBadPicture, err)
+	}
+
+	parsed, err := ogg.ParseTags(tags.Marshal())
+	if err != nil {
+		t.Fatalf("parse tags: %s", err.Error())
+	}
+	pictures, err := parsed.Pictures()
+	if err != nil {
+		t.Fatalf("decode pictures: %s", err.Error())
+	}
+
+	if len(pictures) != 1 {
+		t.Fatalf("pictures count should be equal 1, current %d", len(pictures))
+	}
+	p := pictures[0]
+	if p.Type != ogg.PictureFrontCover || p.MIME != "image/png" || p.Description != "cover" {
+		t.Fatalf("unexpected picture: %+v", p)
+	}
+	if p.Width != 64 || p.Height != 48 || p.Depth != 32 {
+		t.Fatalf("picture should be 64x48x32, current %dx%dx%d", p.Width, p.Height, p.Depth)
+	}
+	if !bytes.Equal(p.Data, data.Bytes()) {
+		t.Fatal("picture data differs")
+	}
+}
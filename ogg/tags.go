@@ -0,0 +1,123 @@
+package ogg
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// ErrBadTags is returned for malformed OpusTags packets.
+var ErrBadTags = errors.New("ogg: malformed OpusTags packet")
+
+// Tags is the content of an OpusTags comment header as described in
+// RFC 7845, section 5.2. Comments are stored in "KEY=value" form.
+type Tags struct {
+	Vendor   string
+	Comments []string
+	// Padding is the number of zero bytes written after the comment list.
+	// Readers discard them, which makes it possible to grow the comments
+	// later without changing the size of the header pages.
+	Padding int
+}
+
+// Add appends a comment with the given key and value.
+func (t *Tags) Add(key, value string) {
+	t.Comments = append(t.Comments, key+"="+value)
+}
+
+// Set replaces all comments with the given key by a single new one.
+func (t *Tags) Set(key, value string) {
+	t.Delete(key)
+	t.Add(key, value)
+}
+
+// Delete removes all comments with the given key. Keys are compared
+// case-insensitively as required by the Vorbis comment specification.
+func (t *Tags) Delete(key string) {
+	comments := t.Comments[:0]
+	for _, c := range t.Comments {
+		if k, _, _ := strings.Cut(c, "="); !strings.EqualFold(k, key) {
+			comments = append(comments, c)
+		}
+	}
+	t.Comments = comments
+}
+
+// Marshal encodes the tags into an OpusTags packet.
+func (t Tags) Marshal() []byte {
+	size := 8 + 4 + len(t.Vendor) + 4 + t.Padding
+	for _, c := range t.Comments {
+		size += 4 + len(c)
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, "OpusTags"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(t.Vendor)))
+	b = append(b, t.Vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(t.Comments)))
+	for _, c := range t.Comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(c)))
+		b = append(b, c...)
+	}
+
+	return append(b, make([]byte, t.Padding)...)
+}
+
+// ParseTags decodes an OpusTags packet. Padding after the comment list is
+// not kept.
+func ParseTags(b []byte) (Tags, error) {
+	if len(b) < 8 || string(b[:8]) != "OpusTags" {
+		return Tags{}, ErrBadTags
+	}
+	b = b[8:]
+
+	// The minimal header written by New without WithTags is empty.
+	if len(b) < 4 {
+		return Tags{}, nil
+	}
+
+	vendor, b, ok := tagsString(b)
+	if !ok {
+		return Tags{}, ErrBadTags
+	}
+	t := Tags{Vendor: vendor}
+
+	if len(b) < 4 {
+		return Tags{}, ErrBadTags
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+
+	for i := uint32(0); i < count; i++ {
+		var c string
+		if c, b, ok = tagsString(b); !ok {
+			return Tags{}, ErrBadTags
+		}
+		t.Comments = append(t.Comments, c)
+	}
+
+	return t, nil
+}
+
+// Get returns the values of all comments with the given key.
+func (t Tags) Get(key string) []string {
+	var values []string
+	for _, c := range t.Comments {
+		if k, v, _ := strings.Cut(c, "="); strings.EqualFold(k, key) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// tagsString reads a length-prefixed string.
+func tagsString(b []byte) (string, []byte, bool) {
+	if len(b) < 4 {
+		return "", nil, false
+	}
+	n := binary.LittleEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-4) {
+		return "", nil, false
+	}
+	return string(b[4 : 4+n]), b[4+n:], true
+}
@@ -0,0 +1,404 @@
+package oggopus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/opus"
+)
+
+const (
+	// encoderLookahead is how far past a span opus.EncodeSpan reads.
+	encoderLookahead = SampleRate/400 + SampleRate/250
+	// frameUnit is the shortest opus frame, 2.5 ms.
+	frameUnit = SampleRate / 400
+	// historySamples is how much of a link is kept before its last packet
+	// for decoding the audio before a join.
+	historySamples = 3 * preRoll
+)
+
+var ErrIncompatibleHeaders = errors.New("incompatible opus headers")
+
+// ConcatOption configures Concat.
+type ConcatOption func(*concatenator)
+
+// ChainIncompatible makes Concat start a new link of a chained stream for an
+// input whose headers do not match those of the previous input, instead of
+// failing with ErrIncompatibleHeaders.
+func ChainIncompatible() ConcatOption {
+	return func(c *concatenator) {
+		c.chain = true
+	}
+}
+
+// ReencodeJoins makes Concat keep inputs in one logical stream where their
+// packets overlap at a join, by decoding and re-encoding the packets around
+// it, instead of starting a new link. This needs the packet boundaries of
+// the two inputs to be a multiple of 2.5 ms apart; other joins still start
+// a new link.
+func ReencodeJoins() ConcatOption {
+	return func(c *concatenator) {
+		c.reencodeJoins = true
+	}
+}
+
+// Concat copies the Opus streams of srcs one after another into dst,
+// without decoding or re-encoding them. The packets are repaginated and
+// their granule positions rebased so that every input continues where the
+// previous one ended. Headers and comments are taken from the first input,
+// apart from a DURATION comment, which is dropped.
+//
+// Joins are sample-exact: the end trimming of the previous input and the
+// pre-skip of the next one are both kept. Where the next input carries on
+// with the packets of the previous one, as the parts of a file split by Cut
+// do, or starts on a packet boundary right after it, the packets are
+// copied into the same logical stream. Otherwise the next input starts a
+// new link of a chained stream with its own pre-skip, and the previous
+// link is trimmed at its end by its last granule position, unless
+// ReencodeJoins is used.
+//
+// All inputs must have the same channel count, output gain and channel
+// mapping family 0, unless ChainIncompatible is used.
+func Concat(dst io.Writer, srcs []io.Reader, opts ...ConcatOption) error {
+	c := concatenator{dst: dst}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	for i, src := range srcs {
+		if err := c.add(src); err != nil {
+			return fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+
+	return c.finish()
+}
+
+type concatenator struct {
+	dst           io.Writer
+	chain         bool
+	reencodeJoins bool
+
+	packer  *ogg.Packer
+	head    Head
+	serial  uint32
+	history []packet // packets written before pending, see historySamples
+	pending *packet  // last packet of the link, not written yet
+}
+
+func (c *concatenator) add(src io.Reader) error {
+	r, err := NewReader(src)
+	if err != nil {
+		return err
+	}
+	if r.head.MappingFamily != 0 {
+		return ErrUnsupportedMapping
+	}
+
+	if c.pending == nil {
+		// Nothing has been written yet.
+		if c.packer == nil {
+			c.serial = r.serial
+		}
+		if err := c.startLink(r); err != nil {
+			return err
+		}
+		return c.copyPackets(r, nil, 0)
+	}
+
+	// Read far enough to cover the first packet after the pre-skip and
+	// what the encoder reads after it.
+	var ahead []packet
+	eof := false
+	for len(ahead) == 0 || ahead[len(ahead)-1].start < int64(r.head.PreSkip)+maxPacketSamples+encoderLookahead {
+		p, err := nextAudioPacket(r.pr)
+		if err == io.EOF {
+			eof = true
+			break
+		}
+		if err != nil {
+			return err
+		}
+		ahead = append(ahead, p)
+	}
+
+	err = c.compatible(r.head)
+	if err == nil {
+		shift, ok, err := c.join(r.head, ahead, eof)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return c.copyPackets(r, nil, shift)
+		}
+	} else if !c.chain {
+		return err
+	}
+
+	if err := c.finish(); err != nil {
+		return err
+	}
+	c.serial++
+	if err := c.startLink(r); err != nil {
+		return err
+	}
+
+	return c.copyPackets(r, ahead, 0)
+}
+
+// join continues the link with the packets of the next input read ahead,
+// whose headers are h, and returns the shift of its granule positions. It
+// returns false if the join cannot be made exact within the link.
+func (c *concatenator) join(h Head, ahead []packet, eof bool) (int64, bool, error) {
+	last := *c.pending
+	end := last.end
+	shift := end - int64(h.PreSkip)
+	// The join is rebuilt from the start of the last packet if it is
+	// trimmed.
+	from := end
+	if last.end < last.start+int64(packetDuration(last.data)) {
+		from = last.start
+	}
+
+	// Packets of the next input that end before the join only warm up
+	// the decoder.
+	i := 0
+	for i < len(ahead) && ahead[i].start+int64(packetDuration(ahead[i].data))+shift <= end {
+		i++
+	}
+	if i == len(ahead) {
+		return shift, true, nil
+	}
+	first := ahead[i]
+	to := first.start + shift
+	if to < end {
+		to += int64(packetDuration(first.data))
+	}
+
+	switch {
+	case from == to:
+	case from < end && first.start+shift == last.start && bytes.Equal(first.data, last.data):
+		// The next input carries on where the previous one was cut.
+		c.pending = nil
+		to = last.start
+	case c.reencodeJoins && (to-from)%frameUnit == 0:
+		if err := c.reencode(ahead, shift, from, to, eof); err != nil {
+			return 0, false, err
+		}
+	default:
+		return 0, false, nil
+	}
+
+	for _, p := range ahead[i:] {
+		if p.start+shift >= to {
+			if err := c.push(packet{data: p.data, start: p.start + shift, end: p.end + shift}); err != nil {
+				return 0, false, err
+			}
+		}
+	}
+
+	return shift, true, nil
+}
+
+// reencode replaces the last packet of the link with packets from granule
+// position from to to, encoded from the audio of the link before the join
+// and that of the packets of the next input in ahead after it.
+func (c *concatenator) reencode(ahead []packet, shift, from, to int64, eof bool) error {
+	channels := int(c.head.Channels)
+	end := c.pending.end
+	base := from - 2*preRoll
+
+	before, err := decodeSpan(channels, append(slices.Clone(c.history), *c.pending), 0, base, end)
+	if err != nil {
+		return err
+	}
+	after, err := decodeSpan(channels, ahead, shift, end, to+encoderLookahead)
+	if err != nil {
+		return err
+	}
+
+	cfg := opus.Config{SampleRate: SampleRate, NumChannels: channels, FrameSize: 20 * time.Millisecond}
+	packets, err := opus.EncodeSpan(cfg, append(before, after...), int(from-base), int(to-base))
+	if err != nil {
+		return fmt.Errorf("encode join: %w", err)
+	}
+
+	c.pending = nil
+	last := ahead[len(ahead)-1].end + shift
+	for pos := from; len(packets) > 0; packets = packets[1:] {
+		if eof && pos >= last {
+			// The next input ends before the join is filled.
+			break
+		}
+		p := packet{data: packets[0], start: pos, end: pos + int64(packetDuration(packets[0]))}
+		if eof {
+			p.end = min(p.end, last)
+		}
+		if err := c.push(p); err != nil {
+			return err
+		}
+		pos += int64(packetDuration(packets[0]))
+	}
+
+	return nil
+}
+
+// copyPackets adds the packets in ahead and the rest of those of r to the
+// link, shifting their granule positions by shift.
+func (c *concatenator) copyPackets(r *Reader, ahead []packet, shift int64) error {
+	for {
+		var p packet
+		if len(ahead) > 0 {
+			p, ahead = ahead[0], ahead[1:]
+		} else {
+			var err error
+			if p, err = nextAudioPacket(r.pr); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if err := c.push(packet{data: p.data, start: p.start + shift, end: p.end + shift}); err != nil {
+			return err
+		}
+	}
+}
+
+// push writes the pending packet and makes p the pending one.
+func (c *concatenator) push(p packet) error {
+	if c.pending != nil {
+		if err := c.write(c.pending.data, false, c.pending.end); err != nil {
+			return err
+		}
+		c.history = append(c.history, *c.pending)
+	}
+	c.pending = &p
+
+	i := 0
+	for i+1 < len(c.history) && c.history[i+1].start <= p.start-historySamples {
+		i++
+	}
+	c.history = c.history[i:]
+
+	return nil
+}
+
+// compatible reports whether packets following h can be decoded with the
+// headers of the current link.
+func (c *concatenator) compatible(h Head) error {
+	switch {
+	case h.Channels != c.head.Channels:
+		return fmt.Errorf("%w: %d channels instead of %d", ErrIncompatibleHeaders, h.Channels, c.head.Channels)
+	case h.OutputGain != c.head.OutputGain:
+		return fmt.Errorf("%w: output gain %d instead of %d", ErrIncompatibleHeaders, h.OutputGain, c.head.OutputGain)
+	}
+	return nil
+}
+
+func (c *concatenator) startLink(r *Reader) error {
+	tags := r.tags
+	tags.Comments = append([]string(nil), tags.Comments...)
+	tags.Delete("DURATION")
+
+	packer, err := ogg.New(r.head.Channels, r.head.InputSampleRate,
+		ogg.WithSerial(c.serial),
+		ogg.WithPreSkip(r.head.PreSkip),
+		ogg.WithOutputGain(r.head.OutputGain),
+		ogg.WithTags(tags),
+	)
+	if err != nil {
+		return fmt.Errorf("create ogg packer: %w", err)
+	}
+
+	c.packer = packer
+	c.head = r.head
+	c.history = nil
+	c.pending = nil
+
+	return nil
+}
+
+// finish writes the last packet of the current link on its EOS page, with
+// its end trimming.
+func (c *concatenator) finish() error {
+	if c.packer == nil {
+		return ErrEmptyRange
+	}
+	if c.pending == nil {
+		return c.write(nil, true, 0)
+	}
+
+	return c.write(c.pending.data, true, c.pending.end)
+}
+
+func (c *concatenator) write(data []byte, eos bool, granule int64) error {
+	if err := c.packer.AddChunkWithGranule(data, eos, granule); err != nil {
+		return fmt.Errorf("add packet: %w", err)
+	}
+	if _, err := c.packer.WritePagesTo(c.dst); err != nil {
+		return fmt.Errorf("write pages: %w", err)
+	}
+
+	return nil
+}
+
+// nextAudioPacket returns the next packet of pr that holds any audio.
+func nextAudioPacket(pr *packetReader) (packet, error) {
+	for {
+		p, err := pr.next()
+		if err == io.EOF {
+			return packet{}, err
+		}
+		if err != nil {
+			return packet{}, fmt.Errorf("read packet: %w", err)
+		}
+		if packetDuration(p.data) > 0 && p.end > p.start {
+			return p, nil
+		}
+	}
+}
+
+// decodeSpan decodes packets with a new decoder and returns the samples
+// from granule position from to to, with the granule positions of the
+// packets shifted by shift. Samples no packet covers are silent.
+func decodeSpan(channels int, packets []packet, shift, from, to int64) ([]int16, error) {
+	dec, err := newDecoder(channels)
+	if err != nil {
+		return nil, err
+	}
+
+	pcm := make([]int16, int(to-from)*channels)
+	buf := make([]int16, maxPacketSamples*channels)
+	for _, p := range packets {
+		n, err := dec.Decode(p.data, buf)
+		if err != nil {
+			n = packetDuration(p.data)
+			if err := dec.DecodePLC(buf[:n*channels]); err != nil {
+				clear(buf[:n*channels])
+			}
+		}
+		for i := 0; i < n; i++ {
+			if pos := p.start + shift + int64(i); pos >= from && pos < to {
+				copy(pcm[int(pos-from)*channels:], buf[i*channels:(i+1)*channels])
+			}
+		}
+	}
+
+	return pcm, nil
+}
+
+// packetDuration returns the number of 48 kHz samples in an opus packet, or
+// 0 if it is malformed.
+func packetDuration(packet []byte) int {
+	n, err := PacketSamples(packet)
+	if err != nil {
+		return 0
+	}
+	return n
+}
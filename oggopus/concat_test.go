@@ -0,0 +1,271 @@
+package oggopus_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/opus"
+)
+
+func TestConcat(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	oggData := oggOpusData(t, sourcePCMData)
+
+	var first, second bytes.Buffer
+	if err := oggopus.Cut(bytes.NewReader(oggData), &first, 0, 2*time.Second); err != nil {
+		t.Fatalf("cut first part: %s", err.Error())
+	}
+	if err := oggopus.Cut(bytes.NewReader(oggData), &second, 2*time.Second, 0); err != nil {
+		t.Fatalf("cut second part: %s", err.Error())
+	}
+
+	var out bytes.Buffer
+	if err := oggopus.Concat(&out, []io.Reader{&first, &second}); err != nil {
+		t.Fatalf("concat: %s", err.Error())
+	}
+
+	if links := countLinks(t, out.Bytes()); links != 1 {
+		t.Fatalf("links count should be equal 1, current %d", links)
+	}
+
+	pcm := decodeAll(t, out.Bytes())
+	if len(pcm) != len(sourcePCMData) {
+		t.Fatalf("decoded samples count should be equal %d, current %d", len(sourcePCMData), len(pcm))
+	}
+	if e := joinError(pcm, decodeAll(t, oggData), 96000); e > maxJoinError {
+		t.Fatalf("error around the join should be at most %v, current %v", maxJoinError, e)
+	}
+}
+
+func TestConcatReencode(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	// The join falls on a 2.5 ms boundary but not on one of a packet.
+	join := 48840
+	first := trimmedOggData(t, sourcePCMData[:join])
+	second := trimmedOggData(t, sourcePCMData[join:])
+
+	var out bytes.Buffer
+	inputs := []io.Reader{bytes.NewReader(first), bytes.NewReader(second)}
+	if err := oggopus.Concat(&out, inputs, oggopus.ReencodeJoins()); err != nil {
+		t.Fatalf("concat: %s", err.Error())
+	}
+
+	if links := countLinks(t, out.Bytes()); links != 1 {
+		t.Fatalf("links count should be equal 1, current %d", links)
+	}
+
+	pcm := decodeAll(t, out.Bytes())
+	if len(pcm) != len(sourcePCMData) {
+		t.Fatalf("decoded samples count should be equal %d, current %d", len(sourcePCMData), len(pcm))
+	}
+	want := append(decodeAll(t, first), decodeAll(t, second)...)
+	if e := joinError(pcm, want, join); e > maxJoinError {
+		t.Fatalf("error around the join should be at most %v, current %v", maxJoinError, e)
+	}
+}
+
+func TestConcatChained(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+
+	tests := []struct {
+		name string
+		join int
+		opts []oggopus.ConcatOption
+	}{
+		{name: "by default", join: 48840},
+		{name: "misaligned", join: 48100, opts: []oggopus.ConcatOption{oggopus.ReencodeJoins()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := trimmedOggData(t, sourcePCMData[:tt.join])
+			second := trimmedOggData(t, sourcePCMData[tt.join:])
+
+			var out bytes.Buffer
+			inputs := []io.Reader{bytes.NewReader(first), bytes.NewReader(second)}
+			if err := oggopus.Concat(&out, inputs, tt.opts...); err != nil {
+				t.Fatalf("concat: %s", err.Error())
+			}
+
+			offsets := linkOffsets(t, out.Bytes())
+			if len(offsets) != 2 {
+				t.Fatalf("links count should be equal 2, current %d", len(offsets))
+			}
+			links := [][]byte{out.Bytes()[:offsets[1]], out.Bytes()[offsets[1]:]}
+			for i, samples := range []int{tt.join, len(sourcePCMData) - tt.join} {
+				if n := len(decodeAll(t, links[i])); n != samples {
+					t.Fatalf("link %d samples count should be equal %d, current %d", i, samples, n)
+				}
+			}
+
+			// The packets are copied as they are.
+			link := links[1]
+			if !bytes.Equal(audioPackets(t, link), audioPackets(t, second)) {
+				t.Fatal("packets of the second link should be those of the second input")
+			}
+		})
+	}
+}
+
+func TestConcatIncompatible(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	mono := oggOpusData(t, sourcePCMData[:48000])
+
+	stereo := opus.Config{SampleRate: 48000, NumChannels: 2, FrameSize: 20 * time.Millisecond}
+	p, err := packer.New(packer.WithConfig(stereo))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData[:96000]); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	stereoData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	var out bytes.Buffer
+	err = oggopus.Concat(&out, []io.Reader{bytes.NewReader(mono), bytes.NewReader(stereoData)})
+	if !errors.Is(err, oggopus.ErrIncompatibleHeaders) {
+		t.Fatalf("concat error should be %v, current %v", oggopus.ErrIncompatibleHeaders, err)
+	}
+
+	out.Reset()
+	inputs := []io.Reader{bytes.NewReader(mono), bytes.NewReader(stereoData)}
+	if err := oggopus.Concat(&out, inputs, oggopus.ChainIncompatible()); err != nil {
+		t.Fatalf("concat: %s", err.Error())
+	}
+
+	if links := countLinks(t, out.Bytes()); links != 2 {
+		t.Fatalf("chained links count should be equal 2, current %d", links)
+	}
+}
+
+// maxJoinError is the largest error around a join relative to the power of
+// the audio there, about 7 dB of SNR. Being off by the encoder delay or a
+// packet gives errors far above it.
+const maxJoinError = 0.2
+
+// joinError returns the mean squared error of got against want in the
+// 100 ms on both sides of sample at, relative to the power of want there.
+func joinError(got, want []int16, at int) float64 {
+	var mse, power float64
+	for i := max(at-4800, 0); i < min(at+4800, len(got), len(want)); i++ {
+		d := float64(got[i]) - float64(want[i])
+		mse += d * d
+		power += float64(want[i]) * float64(want[i])
+	}
+	if power == 0 {
+		return mse
+	}
+	return mse / power
+}
+
+// trimmedOggData encodes pcm like an encoder that applies pre-skip and end
+// trimming, so that it decodes to exactly pcm.
+func trimmedOggData(t *testing.T, pcm []int16) []byte {
+	t.Helper()
+
+	enc, err := opus.NewEncoder(opus.Config{SampleRate: 48000, NumChannels: 1, FrameSize: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("create encoder: %s", err.Error())
+	}
+	preSkip := enc.Lookahead()
+	packets, err := enc.EncodeWithPadding(append(slices.Clone(pcm), make([]int16, preSkip)...))
+	if err != nil {
+		t.Fatalf("encode: %s", err.Error())
+	}
+
+	p, err := ogg.New(1, 48000, ogg.WithPreSkip(uint16(preSkip)))
+	if err != nil {
+		t.Fatalf("create ogg packer: %s", err.Error())
+	}
+	granule := int64(0)
+	for i, packet := range packets {
+		granule += 960
+		if i == len(packets)-1 {
+			granule = int64(preSkip + len(pcm))
+		}
+		if err := p.AddChunkWithGranule(packet, i == len(packets)-1, granule); err != nil {
+			t.Fatalf("add packet: %s", err.Error())
+		}
+	}
+	oggData, err := p.ReadPages()
+	if err != nil {
+		t.Fatalf("read pages: %s", err.Error())
+	}
+
+	return oggData
+}
+
+func decodeAll(t *testing.T, oggData []byte) []int16 {
+	t.Helper()
+
+	r, err := oggopus.NewReader(bytes.NewReader(oggData))
+	if err != nil {
+		t.Fatalf("create reader: %s", err.Error())
+	}
+	return readAll(t, r)
+}
+
+// audioPackets returns the packets of the first logical stream in oggData
+// after the two header packets, joined together.
+func audioPackets(t *testing.T, oggData []byte) []byte {
+	t.Helper()
+
+	var packets []byte
+	n := 0
+	d := ogg.NewDecoder(bytes.NewReader(oggData))
+	for {
+		page, err := d.Decode()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatalf("decode page: %s", err.Error())
+		}
+		for _, p := range page.Packets {
+			if n++; n > 2 {
+				packets = append(packets, p...)
+			}
+		}
+		if page.Type&ogg.EOS != 0 {
+			return packets
+		}
+	}
+}
+
+func countLinks(t *testing.T, oggData []byte) int {
+	t.Helper()
+
+	return len(linkOffsets(t, oggData))
+}
+
+// linkOffsets returns the offsets of the links in oggData.
+func linkOffsets(t *testing.T, oggData []byte) []int {
+	t.Helper()
+
+	var offsets []int
+	offset := 0
+	d := ogg.NewDecoder(bytes.NewReader(oggData))
+	for {
+		page, err := d.Decode()
+		if err == io.EOF {
+			return offsets
+		}
+		if err != nil {
+			t.Fatalf("decode page: %s", err.Error())
+		}
+		if page.Type&ogg.BOS != 0 {
+			offsets = append(offsets, offset)
+		}
+		offset += page.Size
+	}
+}
@@ -0,0 +1,136 @@
+package oggopus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+var (
+	ErrEmptyRange         = errors.New("range contains no audio")
+	ErrUnsupportedMapping = errors.New("unsupported channel mapping family")
+)
+
+// Cut copies the audio of the Opus stream in src from start to end into dst
+// as a new Ogg Opus file, without decoding or re-encoding it. An end of 0
+// copies up to the end of the stream.
+//
+// Opus packets cannot be split, so the copy starts with the packet holding
+// the sample 80 ms before start, which lets the decoder converge, and ends
+// with the packet holding end. The pre-skip of the new file discards
+// everything before start and the granule position of its last page trims
+// it after end, so the result plays back exactly the requested range.
+//
+// The headers of src are kept apart from the pre-skip. A DURATION comment
+// is dropped, as it no longer applies.
+func Cut(src io.ReadSeeker, dst io.Writer, start, end time.Duration) error {
+	if start < 0 || end != 0 && end <= start {
+		return ErrEmptyRange
+	}
+
+	r, err := NewReader(src)
+	if err != nil {
+		return err
+	}
+	if r.head.MappingFamily != 0 {
+		return ErrUnsupportedMapping
+	}
+
+	from := int64(r.head.PreSkip) + int64(start)*SampleRate/int64(time.Second)
+	to := int64(math.MaxInt64)
+	if end != 0 {
+		to = int64(r.head.PreSkip) + int64(end)*SampleRate/int64(time.Second)
+	}
+
+	if err := r.seekPacket(from - preRoll); err != nil {
+		return err
+	}
+
+	c := cutter{r: r, dst: dst, from: from}
+	for {
+		p, err := r.pr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read packet: %w", err)
+		}
+
+		if p.end <= p.start || p.end <= from-preRoll {
+			continue
+		}
+		if p.start >= to {
+			break
+		}
+
+		if err := c.add(p); err != nil {
+			return err
+		}
+	}
+
+	return c.finish(to)
+}
+
+// cutter writes the packets of a cut. The last packet is held back until it
+// is known to be the last one, as it goes on the EOS page.
+type cutter struct {
+	r    *Reader
+	dst  io.Writer
+	from int64
+
+	packer  *ogg.Packer
+	base    int64 // granule position in the source of the first copied sample
+	pending *packet
+}
+
+func (c *cutter) add(p packet) error {
+	if c.packer == nil {
+		tags := c.r.tags
+		tags.Comments = append([]string(nil), tags.Comments...)
+		tags.Delete("DURATION")
+
+		packer, err := ogg.New(c.r.head.Channels, c.r.head.InputSampleRate,
+			ogg.WithSerial(c.r.serial),
+			ogg.WithPreSkip(uint16(c.from-p.start)),
+			ogg.WithOutputGain(c.r.head.OutputGain),
+			ogg.WithTags(tags),
+		)
+		if err != nil {
+			return fmt.Errorf("create ogg packer: %w", err)
+		}
+		c.packer = packer
+		c.base = p.start
+	}
+
+	if c.pending != nil {
+		if err := c.write(*c.pending, false, c.pending.end); err != nil {
+			return err
+		}
+	}
+	c.pending = &p
+
+	return nil
+}
+
+func (c *cutter) finish(to int64) error {
+	if c.pending == nil || c.pending.end <= c.from {
+		return ErrEmptyRange
+	}
+
+	return c.write(*c.pending, true, min(c.pending.end, to))
+}
+
+func (c *cutter) write(p packet, eos bool, granule int64) error {
+	if err := c.packer.AddChunkWithGranule(p.data, eos, granule-c.base); err != nil {
+		return fmt.Errorf("add packet: %w", err)
+	}
+	if _, err := c.packer.WritePagesTo(c.dst); err != nil {
+		return fmt.Errorf("write pages: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package oggopus_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+func TestCut(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	oggData := oggOpusData(t, sourcePCMData)
+
+	tests := []struct {
+		name       string
+		start, end time.Duration
+		wantLen    int
+		wantErr    error
+	}{
+		{
+			name:    "start",
+			start:   0,
+			end:     500 * time.Millisecond,
+			wantLen: 24000,
+		},
+		{
+			name:    "middle",
+			start:   time.Second + 125*time.Microsecond,
+			end:     2 * time.Second,
+			wantLen: 47994,
+		},
+		{
+			name:    "up to end",
+			start:   4 * time.Second,
+			wantLen: len(sourcePCMData) - 192000,
+		},
+		{
+			name:    "after end",
+			start:   time.Minute,
+			wantErr: oggopus.ErrEmptyRange,
+		},
+		{
+			name:    "reversed",
+			start:   2 * time.Second,
+			end:     time.Second,
+			wantErr: oggopus.ErrEmptyRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := oggopus.Cut(bytes.NewReader(oggData), &out, tt.start, tt.end)
+			if err != tt.wantErr {
+				t.Fatalf("cut error should be %v, current %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			r, err := oggopus.NewReader(&out)
+			if err != nil {
+				t.Fatalf("create reader: %s", err.Error())
+			}
+			if r.Head().PreSkip > 3840+2*960 {
+				t.Fatalf("pre-skip should cover at most the pre-roll, current %d", r.Head().PreSkip)
+			}
+
+			if pcm := readAll(t, r); len(pcm) != tt.wantLen {
+				t.Fatalf("decoded samples count should be equal %d, current %d", tt.wantLen, len(pcm))
+			}
+		})
+	}
+}
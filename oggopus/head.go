@@ -0,0 +1,48 @@
+// Package oggopus reads Ogg Opus files as described in RFC 7845.
+package oggopus
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrBadHead     = errors.New("malformed OpusHead packet")
+	ErrBadPacket   = errors.New("malformed opus packet")
+	ErrNotOpus     = errors.New("no opus stream found")
+	ErrNotSeekable = errors.New("reader is not seekable")
+)
+
+// Head is the content of an OpusHead identification header.
+type Head struct {
+	Version  uint8
+	Channels uint8
+	// PreSkip is the number of 48 kHz samples to discard from the start
+	// of the decoded output.
+	PreSkip         uint16
+	InputSampleRate uint32
+	// OutputGain is the gain to apply to the decoded output in Q7.8 dB.
+	OutputGain    int16
+	MappingFamily uint8
+}
+
+// ParseHead decodes an OpusHead packet.
+func ParseHead(b []byte) (Head, error) {
+	if len(b) < 19 || string(b[:8]) != "OpusHead" {
+		return Head{}, ErrBadHead
+	}
+
+	h := Head{
+		Version:         b[8],
+		Channels:        b[9],
+		PreSkip:         binary.LittleEndian.Uint16(b[10:12]),
+		InputSampleRate: binary.LittleEndian.Uint32(b[12:16]),
+		OutputGain:      int16(binary.LittleEndian.Uint16(b[16:18])),
+		MappingFamily:   b[18],
+	}
+	if h.Version>>4 != 0 || h.Channels == 0 {
+		return Head{}, ErrBadHead
+	}
+
+	return h, nil
+}
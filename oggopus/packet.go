@@ -0,0 +1,44 @@
+package oggopus
+
+// frameSamples is the duration of a single frame in 48 kHz samples for each
+// of the 32 TOC configurations (RFC 6716, section 3.1).
+var frameSamples = [32]int{
+	480, 960, 1920, 2880, // SILK-only NB
+	480, 960, 1920, 2880, // SILK-only MB
+	480, 960, 1920, 2880, // SILK-only WB
+	480, 960, // Hybrid SWB
+	480, 960, // Hybrid FB
+	120, 240, 480, 960, // CELT-only NB
+	120, 240, 480, 960, // CELT-only WB
+	120, 240, 480, 960, // CELT-only SWB
+	120, 240, 480, 960, // CELT-only FB
+}
+
+// maxPacketSamples is the longest duration an opus packet may have, 120 ms.
+const maxPacketSamples = 5760
+
+// PacketSamples returns the duration of an opus packet in 48 kHz samples
+// per channel, read from its TOC byte without decoding it.
+func PacketSamples(packet []byte) (int, error) {
+	if len(packet) == 0 {
+		return 0, ErrBadPacket
+	}
+
+	frames := 1
+	switch packet[0] & 3 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0, ErrBadPacket
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+
+	n := frames * frameSamples[packet[0]>>3]
+	if n == 0 || n > maxPacketSamples {
+		return 0, ErrBadPacket
+	}
+
+	return n, nil
+}
@@ -0,0 +1,36 @@
+package oggopus_test
+
+import (
+	"testing"
+
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+func TestPacketSamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		packet  []byte
+		want    int
+		wantErr bool
+	}{
+		{name: "silk 60 ms", packet: []byte{3 << 3}, want: 2880},
+		{name: "celt 2.5 ms", packet: []byte{16 << 3}, want: 120},
+		{name: "hybrid two 20 ms frames", packet: []byte{15<<3 | 1}, want: 1920},
+		{name: "celt three 20 ms frames", packet: []byte{31<<3 | 3, 3}, want: 2880},
+		{name: "too long", packet: []byte{31<<3 | 3, 7}, wantErr: true},
+		{name: "missing frame count", packet: []byte{31<<3 | 3}, wantErr: true},
+		{name: "empty", packet: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := oggopus.PacketSamples(tt.packet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error should be %t, current %v", tt.wantErr, err)
+			}
+			if n != tt.want {
+				t.Fatalf("samples should be equal %d, current %d", tt.want, n)
+			}
+		})
+	}
+}
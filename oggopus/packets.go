@@ -0,0 +1,154 @@
+package oggopus
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+// skeletonPrefix starts the skeleton packet of ogg.CreateSkeletonTrack.
+var skeletonPrefix = []byte("fishead\x00")
+
+// packet is an opus packet together with the granule positions of its
+// first sample and the sample after its last one.
+type packet struct {
+	data       []byte
+	start, end int64
+}
+
+// packetReader reads the packets of one logical stream and works out their
+// granule positions from the TOC bytes, without decoding them.
+type packetReader struct {
+	dec    *ogg.Decoder
+	serial uint32
+	offset int64 // offset of the next page to read
+
+	granule int64  // granule position at the end of the last packet
+	partial []byte // start of a packet that continues on the next page
+	queue   []packet
+	eos     bool
+}
+
+func newPacketReader(r io.Reader, serial uint32, offset int64) *packetReader {
+	return &packetReader{
+		dec:    ogg.NewDecoder(r),
+		serial: serial,
+		offset: offset,
+	}
+}
+
+// next returns the next packet. It returns io.EOF after the EOS page or at
+// the end of a file cut short.
+func (r *packetReader) next() (packet, error) {
+	for len(r.queue) == 0 {
+		if r.eos {
+			return packet{}, io.EOF
+		}
+
+		page, err := r.nextPage()
+		if err == io.EOF {
+			r.eos = true
+			continue
+		}
+		if err != nil {
+			return packet{}, err
+		}
+
+		r.queuePage(page)
+	}
+
+	p := r.queue[0]
+	r.queue = r.queue[1:]
+
+	return p, nil
+}
+
+// resume makes the reader continue after page, which has already been
+// read. Only a packet continued from it is still returned.
+func (r *packetReader) resume(page ogg.Page) {
+	r.queue = nil
+	r.partial = nil
+	r.granule = page.Granule
+	r.eos = page.Type&ogg.EOS != 0
+	if page.Partial {
+		r.partial = append([]byte(nil), page.Packets[len(page.Packets)-1]...)
+	}
+}
+
+func (r *packetReader) readPage() (ogg.Page, error) {
+	page, err := r.dec.Decode()
+	if err != nil {
+		return ogg.Page{}, err
+	}
+	r.offset += int64(page.Size)
+
+	return page, nil
+}
+
+// nextPage returns the next page of the stream.
+func (r *packetReader) nextPage() (ogg.Page, error) {
+	for {
+		page, err := r.readPage()
+		if err != nil {
+			return ogg.Page{}, err
+		}
+		if page.Serial == r.serial {
+			return page, nil
+		}
+	}
+}
+
+// queuePage queues the packets that end on page. No packet on a page can
+// end after the granule position of the page, so packets are cut short at
+// it; this implements end trimming and gives non-audio packets no
+// duration. A page whose granule position is further ahead than its
+// packets account for starts after a gap. Only opus packets count towards
+// that: the skeleton packet that Packer puts into the opus stream has no
+// duration, even on a page at the end of a gap.
+func (r *packetReader) queuePage(page ogg.Page) {
+	packets := page.Packets
+	if page.Type&ogg.COP != 0 && len(packets) > 0 {
+		if r.partial != nil {
+			packets[0] = append(r.partial, packets[0]...)
+		} else {
+			packets = packets[1:]
+		}
+	}
+	r.partial = nil
+
+	if page.Partial && len(packets) > 0 {
+		r.partial = append([]byte(nil), packets[len(packets)-1]...)
+		packets = packets[:len(packets)-1]
+	}
+
+	durations := make([]int, len(packets))
+	total := int64(0)
+	for i, p := range packets {
+		if bytes.HasPrefix(p, skeletonPrefix) {
+			continue
+		}
+		durations[i], _ = PacketSamples(p)
+		total += int64(durations[i])
+	}
+
+	if page.Granule >= 0 && page.Type&ogg.EOS == 0 {
+		r.granule = max(r.granule, page.Granule-total)
+	}
+
+	for i, p := range packets {
+		start, end := r.granule, r.granule+int64(durations[i])
+		r.granule = end
+		if page.Granule >= 0 {
+			start, end = min(start, page.Granule), min(end, page.Granule)
+		}
+		r.queue = append(r.queue, packet{data: p, start: start, end: end})
+	}
+
+	if page.Granule >= 0 {
+		r.granule = page.Granule
+	}
+	if page.Type&ogg.EOS != 0 {
+		r.eos = true
+	}
+}
@@ -0,0 +1,181 @@
+package oggopus
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+// Info describes an Ogg Opus file as found by Probe.
+type Info struct {
+	// Links holds one entry per link of a chained file, or a single one.
+	Links []Link
+	// Duration is the total duration of all links.
+	Duration time.Duration
+	// Size is the size of the file in bytes.
+	Size int64
+}
+
+// Link describes the Opus stream of one link of a file.
+type Link struct {
+	Serial uint32
+	Head   Head
+	Tags   ogg.Tags
+	// Pictures and Chapters are parsed from Tags.
+	Pictures []ogg.Picture
+	Chapters []ogg.Chapter
+	// Offset and Size locate the link in the file in bytes, including the
+	// pages of streams grouped with the Opus stream.
+	Offset, Size int64
+	// Duration is the granule position of the last page minus pre-skip.
+	Duration time.Duration
+	// Bitrate is the average bitrate of the link in bits per second,
+	// estimated from its size and duration.
+	Bitrate int
+	// Pages is the number of pages of the Opus stream, taken from the
+	// sequence number of its last page.
+	Pages int
+}
+
+// Probe reads the headers and the last page of every link of the Ogg Opus
+// file in r without decoding any audio. Links of chained files are found by
+// bisecting over the serial numbers of the pages, so only a few pages per
+// link are read even for long files.
+func Probe(r io.ReadSeeker) (Info, error) {
+	origin, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Info{}, fmt.Errorf("get reader position: %w", err)
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Info{}, fmt.Errorf("get reader size: %w", err)
+	}
+
+	p := prober{rs: r, origin: origin, size: end - origin}
+	info := Info{Size: p.size}
+	for off := int64(0); off < p.size; {
+		link, err := p.link(off)
+		if err != nil {
+			return Info{}, fmt.Errorf("probe link at %d: %w", off, err)
+		}
+		info.Links = append(info.Links, link)
+		info.Duration += link.Duration
+		off += link.Size
+	}
+	if len(info.Links) == 0 {
+		return Info{}, ErrNotOpus
+	}
+
+	return info, nil
+}
+
+type prober struct {
+	rs     io.ReadSeeker
+	origin int64
+	size   int64
+}
+
+// link probes the link starting at off.
+func (p *prober) link(off int64) (Link, error) {
+	if _, err := p.rs.Seek(p.origin+off, io.SeekStart); err != nil {
+		return Link{}, fmt.Errorf("seek: %w", err)
+	}
+	r := &Reader{pr: newPacketReader(p.rs, 0, 0)}
+	if err := r.readHeaders(); err != nil {
+		return Link{}, err
+	}
+
+	end, err := p.linkEnd(off+r.dataStart, r.group)
+	if err != nil {
+		return Link{}, err
+	}
+
+	pictures, err := r.tags.Pictures()
+	if err != nil {
+		return Link{}, fmt.Errorf("decode pictures: %w", err)
+	}
+	chapters, err := r.tags.Chapters()
+	if err != nil {
+		return Link{}, fmt.Errorf("parse chapters: %w", err)
+	}
+
+	link := Link{
+		Serial:   r.serial,
+		Head:     r.head,
+		Tags:     r.tags,
+		Pictures: pictures,
+		Chapters: chapters,
+		Offset:   off,
+		Size:     end - off,
+	}
+
+	isOpus := func(page ogg.Page) bool { return page.Serial == r.serial }
+	last, page, err := findPageBefore(p.rs, p.origin, off, end, isOpus)
+	if err != nil {
+		return Link{}, fmt.Errorf("find last page: %w", err)
+	}
+	link.Pages = int(page.Sequence) + 1
+
+	// The last pages may carry only the start of a packet.
+	if page.Granule < 0 {
+		_, page, err = findPageBefore(p.rs, p.origin, off, last, func(page ogg.Page) bool {
+			return isOpus(page) && page.Granule >= 0
+		})
+		if err != nil && err != errNoPage {
+			return Link{}, fmt.Errorf("find last page: %w", err)
+		}
+	}
+
+	samples := max(page.Granule-int64(r.head.PreSkip), 0)
+	link.Duration = time.Duration(samples) * time.Second / SampleRate
+	if samples > 0 {
+		link.Bitrate = int(link.Size * 8 * SampleRate / samples)
+	}
+
+	return link, nil
+}
+
+// linkEnd returns the offset at which the link whose data pages start at
+// off ends, that is the first page at or after off belonging to none of the
+// streams in group, or the end of the file. Links are contiguous, so this
+// is found by bisection.
+func (p *prober) linkEnd(off int64, group []uint32) (int64, error) {
+	anyPage := func(ogg.Page) bool { return true }
+
+	lo, hi := off, p.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		next, page, err := findPageAfter(p.rs, p.origin, mid, hi, anyPage)
+		if err == errNoPage {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if slices.Contains(group, page.Serial) {
+			lo = next + int64(page.Size)
+		} else {
+			hi = mid
+		}
+	}
+
+	// lo ends a page of the link, or is where its data starts.
+	for {
+		next, page, err := findPageAfter(p.rs, p.origin, lo, p.size, anyPage)
+		if err == errNoPage {
+			return p.size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !slices.Contains(group, page.Serial) {
+			return next, nil
+		}
+		lo = next + int64(page.Size)
+	}
+}
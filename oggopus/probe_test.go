@@ -0,0 +1,154 @@
+package oggopus_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/opus"
+)
+
+func TestProbe(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	oggData := oggOpusData(t, sourcePCMData)
+
+	info, err := oggopus.Probe(bytes.NewReader(oggData))
+	if err != nil {
+		t.Fatalf("probe: %s", err.Error())
+	}
+
+	if len(info.Links) != 1 {
+		t.Fatalf("links count should be equal 1, current %d", len(info.Links))
+	}
+	link := info.Links[0]
+	if link.Head.Channels != 1 || link.Head.InputSampleRate != 48000 {
+		t.Fatalf("unexpected head: %+v", link.Head)
+	}
+	if link.Size != int64(len(oggData)) || info.Size != int64(len(oggData)) {
+		t.Fatalf("link size should be equal %d, current %d", len(oggData), link.Size)
+	}
+
+	// Without end trimming the duration is rounded up to whole 60 ms packets.
+	wantDuration := time.Duration((len(sourcePCMData)+2879)/2880*2880) * time.Second / 48000
+	if info.Duration != wantDuration {
+		t.Fatalf("duration should be equal %s, current %s", wantDuration, info.Duration)
+	}
+	if link.Pages < len(sourcePCMData)/2880 {
+		t.Fatalf("pages count should be at least %d, current %d", len(sourcePCMData)/2880, link.Pages)
+	}
+	if link.Bitrate <= 0 {
+		t.Fatalf("bitrate should be positive, current %d", link.Bitrate)
+	}
+}
+
+func TestProbeChained(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+
+	p, err := packer.New(packer.WithTag("TITLE", "intro"))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData[:48000]); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	stereo := opus.Config{SampleRate: 24000, NumChannels: 2, FrameSize: 20 * time.Millisecond}
+	if err := p.StartNewChain(packer.WithConfig(stereo), packer.WithTag("TITLE", "news")); err != nil {
+		t.Fatalf("start new chain: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData[:48000]); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	oggData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	info, err := oggopus.Probe(bytes.NewReader(oggData))
+	if err != nil {
+		t.Fatalf("probe: %s", err.Error())
+	}
+
+	if len(info.Links) != 2 {
+		t.Fatalf("links count should be equal 2, current %d", len(info.Links))
+	}
+	first, second := info.Links[0], info.Links[1]
+	if first.Serial == second.Serial {
+		t.Fatalf("links should have different serials, both are %d", first.Serial)
+	}
+	if first.Offset+first.Size != second.Offset || second.Offset+second.Size != int64(len(oggData)) {
+		t.Fatalf("links should cover the file, current %+v %+v", first, second)
+	}
+	if title := second.Tags.Get("TITLE"); len(title) != 1 || title[0] != "news" {
+		t.Fatalf("second link title should be news, current %v", title)
+	}
+	if second.Head.Channels != 2 || second.Head.InputSampleRate != 24000 {
+		t.Fatalf("unexpected head of second link: %+v", second.Head)
+	}
+	// The first link is rounded up to whole 60 ms packets.
+	if first.Duration != 1020*time.Millisecond || second.Duration != time.Second {
+		t.Fatalf("link durations should be equal 1.02s and 1s, current %s and %s", first.Duration, second.Duration)
+	}
+	if info.Duration != 2020*time.Millisecond {
+		t.Fatalf("duration should be equal 2.02s, current %s", info.Duration)
+	}
+}
+
+func TestProbePictures(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	fname := filepath.Join(t.TempDir(), "cover.ogg")
+
+	// The picture makes the comment header span several pages.
+	cover := ogg.Picture{
+		Type:   ogg.PictureFrontCover,
+		MIME:   "image/jpeg",
+		Width:  600,
+		Height: 600,
+		Data:   bytes.Repeat([]byte{0xa5}, 150000),
+	}
+
+	sink, err := packer.NewFileSink(fname, -1)
+	if err != nil {
+		t.Fatalf("create file sink: %s", err.Error())
+	}
+	p, err := packer.New(packer.WithWriter(sink), packer.WithPicture(cover))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("close packer: %s", err.Error())
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close file sink: %s", err.Error())
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatalf("open file: %s", err.Error())
+	}
+	defer f.Close()
+
+	info, err := oggopus.Probe(f)
+	if err != nil {
+		t.Fatalf("probe: %s", err.Error())
+	}
+
+	link := info.Links[0]
+	if len(link.Pictures) != 1 {
+		t.Fatalf("pictures count should be equal 1, current %d", len(link.Pictures))
+	}
+	if got := link.Pictures[0]; got.MIME != cover.MIME || got.Width != 600 || !bytes.Equal(got.Data, cover.Data) {
+		t.Fatalf("picture differs: %s %dx%d", got.MIME, got.Width, got.Height)
+	}
+	// The header pages were patched with the final duration on Close.
+	if len(link.Tags.Get("DURATION")) != 1 {
+		t.Fatalf("tags should contain the duration, current %d comments", len(link.Tags.Comments))
+	}
+}
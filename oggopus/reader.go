@@ -0,0 +1,239 @@
+package oggopus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	opus "gopkg.in/hraban/opus.v2"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+// SampleRate is the rate of the decoded output and of granule positions.
+const SampleRate = ogg.GranuleRate
+
+// Reader decodes the first Opus logical stream of an Ogg file into 48 kHz
+// interleaved PCM. Pre-skip, end trimming and the output gain of the
+// OpusHead header are applied, gaps in the granule positions are filled
+// with silence and packets that fail to decode are concealed. Pages of
+// other logical streams are skipped, and reading stops at the end of the
+// Opus stream.
+type Reader struct {
+	rs     io.ReadSeeker // nil if the source is not seekable
+	origin int64         // position of the stream in rs
+	pr     *packetReader
+
+	head      Head
+	tags      ogg.Tags
+	serial    uint32
+	group     []uint32 // serials of all streams grouped with the opus stream
+	dataStart int64    // offset of the first page after the headers
+
+	opusDecoder *opus.Decoder
+	granule     int64 // granule position at the end of the last decoded packet
+	skipTo      int64 // decoded samples before this granule are discarded
+	pcm         []int16
+	pos         int64 // granule position of the first sample in pcm
+	pcmBuf      []int16
+	decoded     bool // a packet was decoded since the start or last seek
+}
+
+// NewReader reads the headers of the first Opus stream in r. If r is an
+// io.ReadSeeker, the Reader supports SeekTime.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		origin, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("get reader position: %w", err)
+		}
+		rd.rs = rs
+		rd.origin = origin
+	}
+	rd.pr = newPacketReader(r, 0, 0)
+
+	if err := rd.readHeaders(); err != nil {
+		return nil, err
+	}
+
+	d, err := newDecoder(int(rd.head.Channels))
+	if err != nil {
+		return nil, err
+	}
+	rd.opusDecoder = d
+	rd.pcmBuf = make([]int16, maxPacketSamples*int(rd.head.Channels))
+	rd.skipTo = int64(rd.head.PreSkip)
+
+	return rd, nil
+}
+
+// Head returns the identification header of the stream.
+func (r *Reader) Head() Head {
+	return r.head
+}
+
+// Tags returns the comment header of the stream.
+func (r *Reader) Tags() ogg.Tags {
+	return r.tags
+}
+
+// Serial returns the serial number of the stream.
+func (r *Reader) Serial() uint32 {
+	return r.serial
+}
+
+// Position returns the time of the next sample Read returns.
+func (r *Reader) Position() time.Duration {
+	pos := r.granule
+	if len(r.pcm) > 0 {
+		pos = r.pos
+	}
+	pos = max(pos, r.skipTo) - int64(r.head.PreSkip)
+	return time.Duration(pos) * time.Second / SampleRate
+}
+
+// Read decodes interleaved samples into pcm and returns how many values
+// were written. It returns io.EOF at the end of the stream.
+func (r *Reader) Read(pcm []int16) (int, error) {
+	for len(r.pcm) == 0 {
+		p, err := r.pr.next()
+		if err != nil {
+			return 0, err
+		}
+
+		r.decodePacket(p)
+	}
+
+	n := copy(pcm, r.pcm)
+	r.pcm = r.pcm[n:]
+	r.pos += int64(n / int(r.head.Channels))
+
+	return n, nil
+}
+
+func (r *Reader) readHeaders() error {
+	var headers [][]byte
+	var partial []byte
+	for len(headers) < 2 {
+		page, err := r.pr.readPage()
+		if err == io.EOF {
+			return ErrNotOpus
+		}
+		if err != nil {
+			return fmt.Errorf("read header page: %w", err)
+		}
+
+		if page.Type&ogg.BOS != 0 {
+			r.group = append(r.group, page.Serial)
+		}
+		if len(headers) == 0 {
+			if page.Type&ogg.BOS == 0 {
+				return ErrNotOpus
+			}
+			if len(page.Packets) == 0 || !bytes.HasPrefix(page.Packets[0], []byte("OpusHead")) {
+				continue // another logical stream of a grouped file
+			}
+			r.serial = page.Serial
+			r.pr.serial = page.Serial
+		}
+		if page.Serial != r.serial {
+			continue
+		}
+
+		for i, packet := range page.Packets {
+			if i == 0 && page.Type&ogg.COP != 0 {
+				packet = append(partial, packet...)
+			}
+			if i == len(page.Packets)-1 && page.Partial {
+				partial = append([]byte(nil), packet...)
+				break
+			}
+			headers = append(headers, packet)
+		}
+	}
+
+	head, err := ParseHead(headers[0])
+	if err != nil {
+		return err
+	}
+	tags, err := ogg.ParseTags(headers[1])
+	if err != nil {
+		return fmt.Errorf("parse tags: %w", err)
+	}
+
+	r.head = head
+	r.tags = tags
+	r.dataStart = r.pr.offset
+
+	return nil
+}
+
+// decodePacket decodes p and keeps the samples of it that are not
+// discarded. A packet that cannot be decoded keeps its place on the
+// timeline: its duration is filled by the loss concealment of the decoder,
+// or with silence if that fails too.
+func (r *Reader) decodePacket(p packet) {
+	n, err := r.opusDecoder.Decode(p.data, r.pcmBuf)
+	if err != nil {
+		if p.end <= p.start {
+			return // not audio, like a skeleton packet
+		}
+		n = int(p.end - p.start)
+		pcm := r.pcmBuf[:n*int(r.head.Channels)]
+		if err := r.opusDecoder.DecodePLC(pcm); err != nil {
+			clear(pcm)
+		}
+	}
+	// Time the granule positions skip between packets is silence.
+	if r.decoded && p.start > r.granule {
+		r.keep(r.granule, p.start, nil)
+	}
+	r.decoded = true
+	r.granule = p.end
+
+	r.keep(p.start, min(p.end, p.start+int64(n)), r.pcmBuf)
+}
+
+// keep adds the samples from start to end that are not discarded to the
+// decoded output. pcm starts at start; nil stands for silence.
+func (r *Reader) keep(start, end int64, pcm []int16) {
+	from := max(start, r.skipTo)
+	if from >= end {
+		return
+	}
+
+	channels := int(r.head.Channels)
+	if len(r.pcm) == 0 {
+		r.pos = from
+	}
+	decoded := len(r.pcm)
+	if pcm == nil {
+		r.pcm = append(r.pcm, make([]int16, int(end-from)*channels)...)
+		return
+	}
+	r.pcm = append(r.pcm, pcm[int(from-start)*channels:int(end-start)*channels]...)
+	applyGain(r.pcm[decoded:], r.head.OutputGain)
+}
+
+func newDecoder(channels int) (*opus.Decoder, error) {
+	d, err := opus.NewDecoder(SampleRate, channels)
+	if err != nil {
+		return nil, fmt.Errorf("create opus decoder: %w", err)
+	}
+	return d, nil
+}
+
+// applyGain scales pcm by gain in Q7.8 dB.
+func applyGain(pcm []int16, gain int16) {
+	if gain == 0 {
+		return
+	}
+
+	factor := math.Pow(10, float64(gain)/(20*256))
+	for i, v := range pcm {
+		pcm[i] = int16(max(min(math.Round(float64(v)*factor), math.MaxInt16), math.MinInt16))
+	}
+}
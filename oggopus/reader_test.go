@@ -0,0 +1,241 @@
+package oggopus_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/opus"
+)
+
+const sourceFname = "../testdata/48k_1ch.pcm"
+
+func TestReader(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	oggData := oggOpusData(t, sourcePCMData)
+
+	r, err := oggopus.NewReader(bytes.NewBuffer(oggData))
+	if err != nil {
+		t.Fatalf("create reader: %s", err.Error())
+	}
+
+	if head := r.Head(); head.Channels != 1 || head.InputSampleRate != 48000 {
+		t.Fatalf("unexpected head: %+v", head)
+	}
+
+	pcm := readAll(t, r)
+	if len(pcm) != len(sourcePCMData) {
+		t.Fatalf("decoded samples count should be equal %d, current %d", len(sourcePCMData), len(pcm))
+	}
+
+	if err := r.SeekTime(time.Second); err != oggopus.ErrNotSeekable {
+		t.Fatalf("seek error should be %v, current %v", oggopus.ErrNotSeekable, err)
+	}
+}
+
+func TestReaderCorruptPacket(t *testing.T) {
+	enc, err := opus.NewEncoder(opus.Config{SampleRate: 48000, NumChannels: 1, FrameSize: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("create encoder: %s", err.Error())
+	}
+	packets, _, err := enc.Encode(make([]int16, 10*960))
+	if err != nil {
+		t.Fatalf("encode: %s", err.Error())
+	}
+
+	for _, corrupt := range []int{0, 4, 9} {
+		p, err := ogg.New(1, 48000)
+		if err != nil {
+			t.Fatalf("create ogg packer: %s", err.Error())
+		}
+		granule := int64(0)
+		for i, packet := range packets {
+			if i == corrupt {
+				// Two frames of 20 ms whose sizes do not add up.
+				packet = []byte{packet[0]&^3 | 1, 0, 0, 0}
+			}
+			n, _ := oggopus.PacketSamples(packet)
+			granule += int64(n)
+			if err := p.AddChunkWithGranule(packet, i == len(packets)-1, granule); err != nil {
+				t.Fatalf("add packet: %s", err.Error())
+			}
+		}
+		oggData, err := p.ReadPages()
+		if err != nil {
+			t.Fatalf("read pages: %s", err.Error())
+		}
+
+		r, err := oggopus.NewReader(bytes.NewReader(oggData))
+		if err != nil {
+			t.Fatalf("create reader: %s", err.Error())
+		}
+		if pcm := readAll(t, r); int64(len(pcm)) != granule {
+			t.Fatalf("decoded samples count with packet %d corrupt should be equal %d, current %d", corrupt, granule, len(pcm))
+		}
+	}
+}
+
+func TestReaderTrailingGap(t *testing.T) {
+	enc, err := opus.NewEncoder(opus.Config{SampleRate: 48000, NumChannels: 1, FrameSize: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("create encoder: %s", err.Error())
+	}
+	packets, _, err := enc.Encode(make([]int16, 5*960))
+	if err != nil {
+		t.Fatalf("encode: %s", err.Error())
+	}
+
+	// The stream ends on a gap, like one written by a packer with
+	// WithSilenceGaps, so the skeleton page is ahead of the last packet.
+	p, err := ogg.New(1, 48000)
+	if err != nil {
+		t.Fatalf("create ogg packer: %s", err.Error())
+	}
+	for _, packet := range packets {
+		if err := p.AddChunk(packet, false, 960); err != nil {
+			t.Fatalf("add packet: %s", err.Error())
+		}
+	}
+	p.AddGap(4800)
+	if err := p.AddSkeleton(p.Duration()); err != nil {
+		t.Fatalf("add skeleton: %s", err.Error())
+	}
+	if err := p.AddChunk([]byte{}, true, 0); err != nil {
+		t.Fatalf("add eos packet: %s", err.Error())
+	}
+	oggData, err := p.ReadPages()
+	if err != nil {
+		t.Fatalf("read pages: %s", err.Error())
+	}
+
+	r, err := oggopus.NewReader(bytes.NewReader(oggData))
+	if err != nil {
+		t.Fatalf("create reader: %s", err.Error())
+	}
+	pcm := readAll(t, r)
+	if want := 5*960 + 4800; len(pcm) != want {
+		t.Fatalf("decoded samples count should be equal %d, current %d", want, len(pcm))
+	}
+	for i, v := range pcm[5*960:] {
+		if v != 0 {
+			t.Fatalf("sample %d of the gap should be silent, current %d", i, v)
+		}
+	}
+
+	// The skeleton packet is not audio, so it is not copied.
+	var out bytes.Buffer
+	if err := oggopus.Cut(bytes.NewReader(oggData), &out, 0, 0); err != nil {
+		t.Fatalf("cut: %s", err.Error())
+	}
+	if bytes.Contains(out.Bytes(), []byte("fishead")) {
+		t.Fatal("cut should not contain the skeleton packet")
+	}
+}
+
+func TestSeek(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	oggData := oggOpusData(t, sourcePCMData)
+
+	tests := []struct {
+		name       string
+		target     time.Duration
+		wantRemain int
+	}{
+		{
+			name:       "start",
+			target:     0,
+			wantRemain: len(sourcePCMData),
+		},
+		{
+			name:       "within pre-roll of start",
+			target:     50 * time.Millisecond,
+			wantRemain: len(sourcePCMData) - 2400,
+		},
+		{
+			name:       "middle",
+			target:     2500*time.Millisecond + 125*time.Microsecond,
+			wantRemain: len(sourcePCMData) - 120006,
+		},
+		{
+			name:       "after end",
+			target:     time.Minute,
+			wantRemain: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := oggopus.Seek(bytes.NewReader(oggData), tt.target)
+			if err != nil {
+				t.Fatalf("seek: %s", err.Error())
+			}
+
+			wantPos := time.Duration(int64(tt.target)*48000/int64(time.Second)) * time.Second / 48000
+			if tt.wantRemain > 0 && r.Position() != wantPos {
+				t.Fatalf("position should be equal %s, current %s", wantPos, r.Position())
+			}
+
+			if pcm := readAll(t, r); len(pcm) != tt.wantRemain {
+				t.Fatalf("remaining samples count should be equal %d, current %d", tt.wantRemain, len(pcm))
+			}
+		})
+	}
+}
+
+func oggOpusData(t *testing.T, pcm []int16) []byte {
+	t.Helper()
+
+	p, err := packer.New()
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(pcm); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+
+	oggData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	return oggData
+}
+
+func readAll(t *testing.T, r *oggopus.Reader) []int16 {
+	t.Helper()
+
+	var pcm []int16
+	buf := make([]int16, 1000)
+	for {
+		n, err := r.Read(buf)
+		pcm = append(pcm, buf[:n]...)
+		if err == io.EOF {
+			return pcm
+		}
+		if err != nil {
+			t.Fatalf("read pcm: %s", err.Error())
+		}
+	}
+}
+
+func pcmData(t *testing.T, fn string) []int16 {
+	t.Helper()
+
+	d, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("open pcm file: %s", err.Error())
+	}
+
+	result := make([]int16, len(d)/2)
+	if err := binary.Read(bytes.NewReader(d), binary.LittleEndian, result); err != nil {
+		t.Fatalf("binary read pcm file: %s", err.Error())
+	}
+
+	return result
+}
@@ -0,0 +1,242 @@
+package oggopus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+const (
+	// preRoll is how much audio is decoded and discarded before a seek
+	// target so that the decoder converges (RFC 7845, section 4.6).
+	preRoll = 3840 // 80 ms
+	// maxPageSize is the largest possible size of an Ogg page.
+	maxPageSize = 27 + 255 + 255*255
+	// seekWindow is how much is scanned at a time when searching for a page.
+	seekWindow = 1 << 16
+)
+
+var errNoPage = errors.New("no page found")
+
+// Seek reads the headers of the Opus stream in r and positions the returned
+// Reader so that its next Read returns the sample at time t.
+func Seek(r io.ReadSeeker, t time.Duration) (*Reader, error) {
+	rd, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rd.SeekTime(t); err != nil {
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+// SeekTime positions the reader so that the next Read returns the sample at
+// time t, counted from the start of the stream after pre-skip. It bisects
+// over the granule positions of the pages to find where decoding has to
+// start 80 ms before t, and discards the decoded pre-roll. Seeking past the
+// end positions the reader at the end of the stream.
+func (r *Reader) SeekTime(t time.Duration) error {
+	if r.rs == nil {
+		return ErrNotSeekable
+	}
+
+	target := int64(r.head.PreSkip) + int64(t)*SampleRate/int64(time.Second)
+	if err := r.seekPacket(target - preRoll); err != nil {
+		return err
+	}
+
+	d, err := newDecoder(int(r.head.Channels))
+	if err != nil {
+		return err
+	}
+	r.opusDecoder = d
+	r.pcm = nil
+	r.decoded = false
+	r.skipTo = max(target, int64(r.head.PreSkip))
+
+	return nil
+}
+
+// seekPacket positions the packet reader so that the next packet it returns
+// starts at or before granule.
+func (r *Reader) seekPacket(granule int64) error {
+	off, page, err := r.findPage(granule)
+	if err != nil {
+		return fmt.Errorf("find page: %w", err)
+	}
+
+	r.granule = 0
+	if off < 0 {
+		return r.seekOffset(r.dataStart)
+	}
+
+	// Reading resumes after the packets that end on the page found, only
+	// a packet continued from it is still needed.
+	if err := r.seekOffset(off + int64(page.Size)); err != nil {
+		return err
+	}
+	r.granule = page.Granule
+	r.pr.resume(page)
+
+	return nil
+}
+
+// findPage returns the offset of the last page of the stream whose granule
+// position is not after granule, or -1 if there is none.
+func (r *Reader) findPage(granule int64) (int64, ogg.Page, error) {
+	size, err := r.rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, ogg.Page{}, fmt.Errorf("get reader size: %w", err)
+	}
+	size -= r.origin
+
+	best := int64(-1)
+	var bestPage ogg.Page
+
+	lo, hi := r.dataStart, size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		off, page, err := r.pageAfter(mid, hi)
+		if err == errNoPage {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return 0, ogg.Page{}, err
+		}
+
+		if page.Granule <= granule {
+			best, bestPage = off, page
+			lo = off + int64(page.Size)
+		} else {
+			hi = mid
+		}
+	}
+
+	// The pages between the last two probes have not been looked at.
+	off := r.dataStart
+	if best >= 0 {
+		off = best + int64(bestPage.Size)
+	}
+	for {
+		next, page, err := r.pageAfter(off, size)
+		if err == errNoPage || err == nil && page.Granule > granule {
+			return best, bestPage, nil
+		}
+		if err != nil {
+			return 0, ogg.Page{}, err
+		}
+		best, bestPage = next, page
+		off = next + int64(page.Size)
+	}
+}
+
+// pageAfter returns the first page of the stream with a granule position
+// that starts at or after off and before limit.
+func (r *Reader) pageAfter(off, limit int64) (int64, ogg.Page, error) {
+	return findPageAfter(r.rs, r.origin, off, limit, func(page ogg.Page) bool {
+		return page.Serial == r.serial && page.Granule >= 0
+	})
+}
+
+// findPageAfter returns the first page accepted by match that starts at or
+// after off and before limit. Offsets are relative to origin.
+func findPageAfter(rs io.ReadSeeker, origin, off, limit int64, match func(ogg.Page) bool) (int64, ogg.Page, error) {
+	buf := make([]byte, seekWindow+maxPageSize)
+	for pos := off; pos < limit; pos += seekWindow {
+		b, err := readWindow(rs, origin+pos, buf)
+		if err != nil {
+			return 0, ogg.Page{}, err
+		}
+
+		for i := 0; i < seekWindow && pos+int64(i) < limit; {
+			j := bytes.Index(b[i:], []byte("OggS"))
+			if j < 0 {
+				break
+			}
+			i += j
+
+			page, err := ogg.ParsePage(b[i:])
+			if err == nil && pos+int64(i) < limit && match(page) {
+				page.Packets = copyPackets(page.Packets)
+				return pos + int64(i), page, nil
+			}
+			i++
+		}
+	}
+
+	return 0, ogg.Page{}, errNoPage
+}
+
+// findPageBefore returns the last page accepted by match that starts at or
+// after off and ends at or before limit. Offsets are relative to origin.
+func findPageBefore(rs io.ReadSeeker, origin, off, limit int64, match func(ogg.Page) bool) (int64, ogg.Page, error) {
+	buf := make([]byte, seekWindow+maxPageSize)
+	for end := limit; end > off; end -= seekWindow {
+		pos := max(end-seekWindow, off)
+		b, err := readWindow(rs, origin+pos, buf[:min(int64(len(buf)), limit-pos)])
+		if err != nil {
+			return 0, ogg.Page{}, err
+		}
+
+		found := int64(-1)
+		var foundPage ogg.Page
+		for i := 0; int64(i) < end-pos; {
+			j := bytes.Index(b[i:], []byte("OggS"))
+			if j < 0 || int64(i+j) >= end-pos {
+				break
+			}
+			i += j
+
+			// Pages crossing limit are cut short in b and fail to parse.
+			page, err := ogg.ParsePage(b[i:])
+			if err == nil && match(page) {
+				found, foundPage = pos+int64(i), page
+			}
+			i++
+		}
+		if found >= 0 {
+			foundPage.Packets = copyPackets(foundPage.Packets)
+			return found, foundPage, nil
+		}
+	}
+
+	return 0, ogg.Page{}, errNoPage
+}
+
+// readWindow fills buf from off as far as the source allows.
+func readWindow(rs io.ReadSeeker, off int64, buf []byte) ([]byte, error) {
+	if _, err := rs.Seek(off, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek: %w", err)
+	}
+	n, err := io.ReadFull(rs, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return buf[:n], nil
+}
+
+// seekOffset makes reading continue with the page at off.
+func (r *Reader) seekOffset(off int64) error {
+	if _, err := r.rs.Seek(r.origin+off, io.SeekStart); err != nil {
+		return fmt.Errorf("seek: %w", err)
+	}
+	r.pr = newPacketReader(r.rs, r.serial, off)
+
+	return nil
+}
+
+func copyPackets(packets [][]byte) [][]byte {
+	c := make([][]byte, len(packets))
+	for i, p := range packets {
+		c[i] = append([]byte(nil), p...)
+	}
+	return c
+}
@@ -7,7 +7,10 @@ import (
 	"gopkg.in/hraban/opus.v2"
 )
 
-var ErrTooLargeLastPacket = errors.New("last packet length is greater than frame size")
+var (
+	ErrTooLargeLastPacket = errors.New("last packet length is greater than frame size")
+	ErrSpan               = errors.New("span is not a multiple of 2.5 ms")
+)
 
 const (
 	FrameSize   = 60
@@ -33,6 +36,7 @@ type Encoder struct {
 	config           Config
 	encoder          *encoderWrapper
 	frameSizeSamples int
+	silence          []byte // cached packet of a silent frame
 }
 
 func NewEncoder(config Config) (*Encoder, error) {
@@ -80,6 +84,48 @@ func (e *Encoder) EncodeWithPadding(samples []int16) ([][]byte, error) {
 	return encoded, nil
 }
 
+// Config returns the configuration the encoder was created with.
+func (e *Encoder) Config() Config {
+	return e.config
+}
+
+// Channels returns the number of interleaved channels the encoder expects.
+func (e *Encoder) Channels() int {
+	return e.config.NumChannels
+}
+
+// Lookahead returns the algorithmic delay of the encoder in samples per
+// channel. Outside of the restricted low-delay mode libopus delays its
+// output by 2.5 ms of lookahead plus 4 ms of delay compensation, and
+// decoders have to skip that much as pre-skip.
+func (e *Encoder) Lookahead() int {
+	return e.config.SampleRate/400 + e.config.SampleRate/250
+}
+
+// SilentPacket returns a packet of one frame of digital silence. It is
+// encoded once by a separate encoder and cached, so stretches of silence
+// cost no encoding. The packet must not be modified.
+func (e *Encoder) SilentPacket() ([]byte, error) {
+	if e.silence != nil {
+		return e.silence, nil
+	}
+
+	encoder, err := newEncoderWrapper(e.config.SampleRate, e.config.NumChannels, opus.AppAudio)
+	if err != nil {
+		return nil, err
+	}
+	silent := &Encoder{encoder: encoder, config: e.config, frameSizeSamples: e.frameSizeSamples}
+	zeros := make([]int16, e.frameSizeSamples)
+	// The first packet of a new encoder is not in a steady state yet.
+	for i := 0; i < 2; i++ {
+		if e.silence, err = silent.encodeOneChunk(zeros); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.silence, nil
+}
+
 func (e *Encoder) encodeOneChunk(samplesChunk []int16) ([]byte, error) {
 	if len(samplesChunk) < e.frameSizeSamples {
 		return []byte{}, nil
@@ -94,6 +140,64 @@ func (e *Encoder) encodeOneChunk(samplesChunk []int16) ([]byte, error) {
 	return oneOpusPacket, nil
 }
 
+// EncodeSpan encodes the samples per channel from from to to of the
+// interleaved pcm with a new encoder, so that the decoded packets line up
+// with them exactly. This is needed to replace packets in the middle of a
+// stream, where no pre-skip hides the delay of the encoder. The span must
+// be a multiple of 2.5 ms; it is cut into frames of cfg.FrameSize and
+// shorter ones at its end.
+//
+// The encoder is primed with 80 ms of pcm before from and reads as far
+// after to as its lookahead reaches; samples outside pcm count as silence.
+func EncodeSpan(cfg Config, pcm []int16, from, to int) ([][]byte, error) {
+	unit := cfg.SampleRate / 400
+	if from < 0 || to < from || (to-from)%unit != 0 {
+		return nil, ErrSpan
+	}
+
+	encoder, err := newEncoderWrapper(cfg.SampleRate, cfg.NumChannels, opus.AppAudio)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := cfg.NumChannels
+	frame := FrameSizeSamples(cfg) / channels
+	prime := (cfg.SampleRate*2/25 + frame - 1) / frame * frame
+	// Input sample i comes out of the decoder lookahead samples later.
+	start := from + cfg.SampleRate/400 + cfg.SampleRate/250 - prime
+	in := make([]int16, (prime+to-from)*channels)
+	for i := range in {
+		if j := start*channels + i; j >= 0 && j < len(pcm) {
+			in[i] = pcm[j]
+		}
+	}
+
+	var packets [][]byte
+	for pos := 0; pos < len(in); {
+		n := frame
+		if pos >= prime*channels {
+			// The end of the span is cut into shorter frames.
+			for _, units := range []int{24, 16, 8, 4, 2, 1} {
+				if n = units * unit; n <= frame && pos+n*channels <= len(in) {
+					break
+				}
+			}
+		}
+
+		packet := make([]byte, 4000)
+		size, err := encoder.encode(in[pos:pos+n*channels], packet)
+		if err != nil {
+			return nil, err
+		}
+		if pos >= prime*channels {
+			packets = append(packets, packet[:size])
+		}
+		pos += n * channels
+	}
+
+	return packets, nil
+}
+
 func FrameSizeSamples(cfg Config) int {
 	frameSizeMillis := cfg.FrameSize.Milliseconds()
 	frameSizeSamples := float32(int64(cfg.NumChannels*cfg.SampleRate)*frameSizeMillis) / 1000
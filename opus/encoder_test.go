@@ -1,9 +1,11 @@
 package opus_test
 
 import (
+	"errors"
 	"math/rand"
 	"testing"
 
+	"github.com/paveldroo/go-ogg-packer/oggopus"
 	"github.com/paveldroo/go-ogg-packer/opus"
 )
 
@@ -111,6 +113,65 @@ func TestEncoder_EncodeWithPadding(t *testing.T) {
 	}
 }
 
+func TestEncodeSpan(t *testing.T) {
+	cfg := opus.NewDefaultConfig()
+	pcmData := generateRandomPCMData(48000)
+
+	tests := []struct {
+		name        string
+		from, to    int
+		wantSamples []int
+		wantErr     error
+	}{
+		{
+			name:        "whole frames",
+			from:        1000,
+			to:          1000 + 2*2880,
+			wantSamples: []int{2880, 2880},
+		},
+		{
+			name:        "short frames at the end",
+			from:        0,
+			to:          2880 + 960 + 240 + 120,
+			wantSamples: []int{2880, 960, 240, 120},
+		},
+		{
+			name:        "near the end of pcm",
+			from:        48000 - 480,
+			to:          48000 + 480,
+			wantSamples: []int{960},
+		},
+		{
+			name:    "misaligned span",
+			from:    0,
+			to:      1000,
+			wantErr: opus.ErrSpan,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packets, err := opus.EncodeSpan(cfg, pcmData, tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error should be %v, current %v", tt.wantErr, err)
+			}
+
+			if len(packets) != len(tt.wantSamples) {
+				t.Fatalf("packets count should be equal %d, current %d", len(tt.wantSamples), len(packets))
+			}
+			for i, packet := range packets {
+				samples, err := oggopus.PacketSamples(packet)
+				if err != nil {
+					t.Fatalf("parse packet %d: %s", i, err.Error())
+				}
+				if samples != tt.wantSamples[i] {
+					t.Fatalf("packet %d should hold %d samples, current %d", i, tt.wantSamples[i], samples)
+				}
+			}
+		})
+	}
+}
+
 func generateRandomPCMData(size int) []int16 {
 	pcm := make([]int16, size)
 	for i := range pcm {
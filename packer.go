@@ -1,10 +1,36 @@
 package packer
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
 
+	"github.com/paveldroo/go-ogg-packer/filter"
+	"github.com/paveldroo/go-ogg-packer/loudness"
 	"github.com/paveldroo/go-ogg-packer/ogg"
 	"github.com/paveldroo/go-ogg-packer/opus"
+	"github.com/paveldroo/go-ogg-packer/vad"
+	"github.com/paveldroo/go-ogg-packer/waveform"
+)
+
+const (
+	vendor = "go-ogg-packer"
+	// startTimeTag is the comment SendPCMChunkAt records the wall-clock
+	// start of a link in, as RFC 3339 time.
+	startTimeTag = "START_TIME"
+	// defaultHeaderReserve is the number of bytes kept free in the OpusTags
+	// header of seekable output so final comments can be patched in on Close.
+	defaultHeaderReserve = 512
+)
+
+var (
+	ErrNoWriter    = errors.New("packer has no output writer")
+	ErrStreaming   = errors.New("packer streams to a writer, use Close instead")
+	ErrClosed      = errors.New("packer is closed")
+	ErrNotSeekable = errors.New("header pages of non-seekable output cannot be changed")
 )
 
 type Packer struct {
@@ -12,69 +38,990 @@ type Packer struct {
 	opusEncoder *opus.Encoder
 	oggPacker   *ogg.Packer
 	pcmBuffer   []int16
+
+	cfg           opus.Config
+	tags          ogg.Tags
+	w             io.Writer
+	start         int64 // position of the output in w
+	written       int64 // bytes written to w
+	linkStart     int64 // offset of the current chain link in the output
+	headerReserve int
+	finalTags     []string
+	chapters      []ogg.Chapter
+	closed        bool
+
+	meter      *loudness.Meter // loudness of the current link
+	levels     *loudness.LevelMeter
+	measured   int64 // samples per channel measured by levels
+	onFrame    func(FrameStats)
+	album      *loudness.Album
+	trackGain  bool // write R128_TRACK_GAIN
+	outputGain bool // normalize with the OpusHead output gain
+
+	samplesPerPixel int
+	peaks           *waveform.Builder // waveform of the current link
+
+	normalize    bool
+	normTarget   float64              // LUFS
+	normCeiling  float64              // dBTP
+	normalizer   *loudness.Normalizer // streaming normalization
+	unnormalized []int16              // buffered PCM for two-pass normalization
+
+	trimConfig *vad.TrimConfig
+	trimmer    *vad.Trimmer
+
+	filters []filter.Filter
+	latency int // samples per channel the filters delay the output by
+
+	serial      uint32
+	trimEnd     bool
+	silenceGaps bool // SendSilence leaves a granule gap
+	samplesIn   int64
+
+	timing    TimestampConfig
+	timed     bool          // SendPCMChunkAt was called in the current link
+	timeBase  time.Duration // timestamp of the start of the link
+	startTime time.Time     // wall-clock time of the start of the link
+	held      []int16       // end of the input held back for a crossfade
+
+	optErr error // first error of an option
+}
+
+// TimestampConfig configures how SendPCMChunkAt places PCM on the
+// timeline.
+type TimestampConfig struct {
+	// Tolerance is the largest gap or overlap that is ignored, so clock
+	// jitter does not insert tiny gaps or drop single samples. Deviations
+	// add up until they exceed it.
+	Tolerance time.Duration
+	// Crossfade blends overlapping audio over up to this long. Without it
+	// overlapping samples of the later chunk are dropped. The end of the
+	// input is held back for as long, until the next chunk or Close.
+	Crossfade time.Duration
+	// Epoch is the wall-clock time of timestamp 0. If it is zero, the
+	// arrival of the first chunk counts as the start.
+	Epoch time.Time
+}
+
+// Option configures optional behaviour of a Packer.
+type Option func(*Packer)
+
+// WithWriter makes the packer stream Ogg pages to w as soon as they are
+// complete instead of keeping the whole file in memory until GetResult.
+// The output has to be finished with Close.
+//
+// If w also implements io.WriterAt or io.WriteSeeker, space is reserved in
+// the header pages and they are rewritten with final information on Close,
+// so the output stays streamable while carrying an accurate duration at
+// the front. An io.WriterAt is assumed to receive the stream at offset 0,
+// an io.WriteSeeker at its position when New is called.
+func WithWriter(w io.Writer) Option {
+	return func(p *Packer) {
+		p.w = w
+	}
+}
+
+// WithHeaderReserve sets the number of bytes reserved in the header pages
+// of seekable output for comments added on Close.
+func WithHeaderReserve(n int) Option {
+	return func(p *Packer) {
+		p.headerReserve = n
+	}
+}
+
+// WithConfig sets the sample rate, channel count and frame size of the PCM
+// input. The default is opus.NewDefaultConfig.
+func WithConfig(cfg opus.Config) Option {
+	return func(p *Packer) {
+		p.cfg = cfg
+	}
+}
+
+// WithTag adds a comment to the OpusTags header.
+func WithTag(key, value string) Option {
+	return func(p *Packer) {
+		p.tags.Add(key, value)
+	}
+}
+
+// WithPicture embeds a picture, such as cover art, in the comment header.
+// See ogg.Tags.AddPicture for how missing image details are filled in.
+func WithPicture(pic ogg.Picture) Option {
+	return func(p *Packer) {
+		if err := p.tags.AddPicture(pic); err != nil && p.optErr == nil {
+			p.optErr = fmt.Errorf("add picture: %w", err)
+		}
+	}
+}
+
+// WithR128TrackGain writes the R128_TRACK_GAIN comment with the gain that
+// brings each link to the -23 LUFS reference loudness. Like SetTag this
+// needs buffered output or a seekable writer.
+func WithR128TrackGain() Option {
+	return func(p *Packer) {
+		p.trackGain = true
+	}
+}
+
+// WithR128OutputGain normalizes each link to the reference loudness with
+// the output gain of the OpusHead header, which every decoder applies.
+// Combined with WithR128TrackGain the track gain is written relative to
+// it, so it is close to 0. This needs buffered output or a seekable
+// writer.
+func WithR128OutputGain() Option {
+	return func(p *Packer) {
+		p.outputGain = true
+	}
+}
+
+// WithNormalization changes the samples themselves to the target loudness
+// in LUFS before they are encoded, with a true peak limiter keeping them
+// below ceiling in dBTP. Unlike the R128 gains this also works for players
+// that ignore them.
+//
+// Buffered output is normalized as a whole with a constant gain when it
+// is finished. With WithWriter the gain follows the short-term loudness,
+// which delays the output by about 1.5 s; the rest is written by Close.
+func WithNormalization(target, ceiling float64) Option {
+	return func(p *Packer) {
+		p.normalize = true
+		p.normTarget = target
+		p.normCeiling = ceiling
+	}
 }
 
-func New() (*Packer, error) {
-	cfg := opus.NewDefaultConfig()
-	encoder, err := opus.NewEncoder(cfg)
+// WithSilenceTrimming runs voice activity detection on the PCM and removes
+// silence as configured by cfg before it is encoded: leading and trailing
+// silence down to a padding, and the middle of long pauses. A zero cfg
+// only detects speech, see Segments. Trimmed silence does not count for
+// granule positions or chapter times; a chapter added while silence is
+// held back may move by the part of it that is removed later.
+func WithSilenceTrimming(cfg vad.TrimConfig) Option {
+	return func(p *Packer) {
+		p.trimConfig = &cfg
+	}
+}
+
+// WithFilters passes the PCM through filters, in the given order, before
+// it is encoded. Their latency is declared as pre-skip, so the decoded
+// output is aligned with the input. The filters are flushed at the end of
+// every link of a chained stream and must match its sample rate and
+// channel count.
+func WithFilters(filters ...filter.Filter) Option {
+	return func(p *Packer) {
+		p.filters = append(p.filters, filters...)
+	}
+}
+
+// FrameStats describes one encoded frame.
+type FrameStats struct {
+	// Time is the start of the frame in the current link.
+	Time time.Duration
+	// Channels holds the levels per channel.
+	Channels []loudness.Levels
+}
+
+// WithFrameStats calls fn with the levels of every frame as it is
+// encoded, for example to drive a live meter. fn runs synchronously in
+// SendPCMChunk, Close and GetResult, and must not keep Channels. With
+// buffered two-pass normalization the frames are only encoded, and
+// reported, when the output is finished.
+func WithFrameStats(fn func(FrameStats)) Option {
+	return func(p *Packer) {
+		p.onFrame = fn
+	}
+}
+
+// WithWaveform builds a waveform of every link while it is encoded, with
+// one min/max pair per samplesPerPixel samples at the sample rate of the
+// configuration. It is returned by Waveform.
+func WithWaveform(samplesPerPixel int) Option {
+	return func(p *Packer) {
+		if samplesPerPixel <= 0 && p.optErr == nil {
+			p.optErr = waveform.ErrSamplesPerPixel
+		}
+		p.samplesPerPixel = samplesPerPixel
+	}
+}
+
+// WithSilenceGaps makes SendSilence leave whole frames of silence out of
+// the stream. The granule position jumps over them instead, which readers
+// such as oggopus.Reader fill with silence; some players skip the gap.
+func WithSilenceGaps() Option {
+	return func(p *Packer) {
+		p.silenceGaps = true
+	}
+}
+
+// WithTimestamps configures SendPCMChunkAt.
+func WithTimestamps(cfg TimestampConfig) Option {
+	return func(p *Packer) {
+		p.timing = cfg
+	}
+}
+
+// WithAlbum adds the loudness meter of every link to a, so the album gain
+// can be written with WriteAlbumGain once all tracks are finished.
+func WithAlbum(a *loudness.Album) Option {
+	return func(p *Packer) {
+		p.album = a
+	}
+}
+
+// withSerial sets the serial number of the logical stream.
+func withSerial(serial uint32) Option {
+	return func(p *Packer) {
+		p.serial = serial
+	}
+}
+
+// withExactLength makes the output decode to exactly the samples that were
+// sent: the encoder lookahead is declared as pre-skip, and the final page
+// trims the padding of the last packet. The skeleton packet is left out
+// because it would end up behind the trimmed packet.
+func withExactLength() Option {
+	return func(p *Packer) {
+		p.trimEnd = true
+	}
+}
+
+func New(opts ...Option) (*Packer, error) {
+	p := &Packer{
+		cfg:           opus.NewDefaultConfig(),
+		tags:          ogg.Tags{Vendor: vendor},
+		headerReserve: defaultHeaderReserve,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.optErr != nil {
+		return nil, p.optErr
+	}
+	if (p.trackGain || p.outputGain) && p.w != nil && !p.seekable() {
+		return nil, ErrNotSeekable
+	}
+
+	if ws, ok := p.w.(io.WriteSeeker); ok && !p.writerAt() {
+		var err error
+		if p.start, err = ws.Seek(0, io.SeekCurrent); err != nil {
+			return nil, fmt.Errorf("get output position: %w", err)
+		}
+	}
+
+	if err := p.startLink(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// StartNewChain ends the current logical stream and starts a new one in the
+// same output, chained as described in RFC 3533: the EOS page of the current
+// stream is immediately followed by the BOS page of the next one, which gets
+// its own serial number, headers and granule positions starting from zero.
+//
+// The options configure the new chain link, for instance with a different
+// WithConfig or WithTag; options for the output as a whole, such as
+// WithWriter, WithFilters or the loudness and trimming options, are
+// ignored. Comments set with SetTag only apply to the link they were set
+// in.
+func (s *Packer) StartNewChain(opts ...Option) error {
+	if s.closed {
+		return ErrClosed
+	}
+
+	next := Packer{cfg: s.cfg, tags: ogg.Tags{Vendor: vendor}}
+	for _, opt := range opts {
+		opt(&next)
+	}
+	if next.optErr != nil {
+		return next.optErr
+	}
+
+	if err := s.endLink(); err != nil {
+		return fmt.Errorf("end chain link: %w", err)
+	}
+
+	s.cfg = next.cfg
+	s.tags = next.tags
+	s.serial = s.oggPacker.Serial() + 1
+	s.linkStart = s.written
+	s.finalTags = nil
+	s.chapters = nil
+	s.samplesIn = 0
+	s.timed = false
+	s.closed = false
+
+	return s.startLink()
+}
+
+// startLink creates the encoders of a logical stream and writes its header
+// pages.
+func (s *Packer) startLink() error {
+	encoder, err := opus.NewEncoder(s.cfg)
 	if err != nil {
-		return nil, fmt.Errorf("create opus encoder: %s", err)
+		return fmt.Errorf("create opus encoder: %s", err)
 	}
 
-	packer, err := ogg.New(uint8(cfg.NumChannels), uint32(cfg.SampleRate))
+	s.latency = 0
+	for _, f := range s.filters {
+		s.latency += f.Latency()
+	}
+
+	var oggOpts []ogg.Option
+	if s.serial != 0 {
+		oggOpts = append(oggOpts, ogg.WithSerial(s.serial))
+	}
+	preSkip := s.latency
+	if s.trimEnd {
+		preSkip += encoder.Lookahead()
+	}
+	if preSkip > 0 {
+		oggOpts = append(oggOpts, ogg.WithPreSkip(uint16(preSkip*ogg.GranuleRate/s.cfg.SampleRate)))
+	}
+	if s.seekable() {
+		reserved := s.tags
+		reserved.Padding = s.headerReserve
+		oggOpts = append(oggOpts, ogg.WithTags(reserved))
+	} else if len(s.tags.Comments) > 0 {
+		oggOpts = append(oggOpts, ogg.WithTags(s.tags))
+	}
+
+	packer, err := ogg.New(uint8(s.cfg.NumChannels), uint32(s.cfg.SampleRate), oggOpts...)
 	if err != nil {
-		return nil, fmt.Errorf("create ogg packer: %w", err)
+		return fmt.Errorf("create ogg packer: %w", err)
 	}
 
-	return &Packer{
-		opusEncoder: encoder,
-		oggPacker:   packer,
-	}, nil
+	s.opusEncoder = encoder
+	s.oggPacker = packer
+	s.meter = loudness.NewMeter(s.cfg.SampleRate, s.cfg.NumChannels)
+	s.levels = loudness.NewLevelMeter(s.cfg.NumChannels)
+	s.measured = 0
+	if s.samplesPerPixel > 0 {
+		s.peaks, _ = waveform.NewBuilder(s.cfg.SampleRate, s.cfg.NumChannels, s.samplesPerPixel)
+	}
+	if s.trimConfig != nil {
+		s.trimmer = vad.NewTrimmer(s.cfg.SampleRate, s.cfg.NumChannels, *s.trimConfig)
+	}
+	if s.normalize && s.w != nil {
+		s.normalizer = loudness.NewNormalizer(s.cfg.SampleRate, s.cfg.NumChannels, s.normTarget, s.normCeiling)
+	}
+	if s.album != nil {
+		s.album.Add(s.meter)
+	}
+
+	if err := s.writePages(); err != nil {
+		return fmt.Errorf("write header pages: %w", err)
+	}
+
+	return nil
+}
+
+// SetTag sets a comment that is written to the OpusTags header when the
+// output is finished, replacing earlier values for the same key. This
+// requires either buffered output or a seekable writer.
+func (s *Packer) SetTag(key, value string) error {
+	if s.closed {
+		return ErrClosed
+	}
+	if s.w != nil && !s.seekable() {
+		return ErrNotSeekable
+	}
+
+	s.finalTags = append(s.finalTags, key, value)
+
+	return nil
+}
+
+// AddChapter marks the start of a chapter at the current position, that is
+// at the next sample to be sent. Chapters are written as CHAPTERxxx and
+// CHAPTERxxxNAME comments when the header is finalized, so like SetTag this
+// needs buffered output or a seekable writer. Chapters belong to the
+// current link of a chained stream.
+func (s *Packer) AddChapter(name string) error {
+	if s.closed {
+		return ErrClosed
+	}
+	if s.w != nil && !s.seekable() {
+		return ErrNotSeekable
+	}
+
+	start := time.Duration(s.samplesOut()) * time.Second / time.Duration(s.cfg.SampleRate)
+	s.chapters = append(s.chapters, ogg.Chapter{Start: start, Name: name})
+
+	return nil
+}
+
+// Loudness returns the integrated loudness in LUFS of the audio encoded in
+// the current link so far, or -Inf if it is silent. With WithNormalization
+// it is measured after normalization, so buffered output reports nothing
+// until it is finished.
+func (s *Packer) Loudness() float64 {
+	return s.meter.Integrated()
+}
+
+// Levels returns the peak and average levels and the share of clipped
+// samples of the audio encoded in the current link so far.
+func (s *Packer) Levels() loudness.LevelSummary {
+	return s.levels.Summary()
+}
+
+// Waveform returns the waveform of the audio encoded in the current link
+// so far, or nil without WithWaveform.
+func (s *Packer) Waveform() *waveform.Waveform {
+	if s.peaks == nil {
+		return nil
+	}
+	return s.peaks.Waveform()
 }
 
 func (s *Packer) SendPCMChunk(chunk []int16) error {
+	if s.closed {
+		return ErrClosed
+	}
+	if err := s.releaseHeld(); err != nil {
+		return err
+	}
+
+	return s.sendPCM(chunk)
+}
+
+// sendPCM passes PCM through the processing stages to the encoders.
+func (s *Packer) sendPCM(chunk []int16) error {
+	s.samplesIn += int64(len(chunk) / s.opusEncoder.Channels())
+	if s.trimmer != nil {
+		chunk = s.trimmer.Process(chunk)
+	}
+
+	return s.normalizeAndEncode(s.runFilters(chunk, 0))
+}
+
+// SendPCMChunkAt sends PCM captured at ts. The timestamp of the first
+// chunk of a link is its start, whose wall-clock time is returned by
+// StartTime and written as the START_TIME comment where the header can
+// still be changed. Later chunks that start after the end of the previous
+// one are preceded by silence; samples overlapping earlier ones are
+// dropped or crossfaded, see TimestampConfig.
+func (s *Packer) SendPCMChunkAt(ts time.Duration, chunk []int16) error {
+	if s.closed {
+		return ErrClosed
+	}
+
+	channels := s.opusEncoder.Channels()
+	if !s.timed {
+		s.timed = true
+		s.timeBase = ts
+		s.startTime = time.Now()
+		if !s.timing.Epoch.IsZero() {
+			s.startTime = s.timing.Epoch.Add(ts)
+		}
+		if s.w == nil || s.seekable() {
+			s.finalTags = append(s.finalTags, startTimeTag, s.startTime.UTC().Format(time.RFC3339Nano))
+		}
+	}
+
+	start := s.samples(ts - s.timeBase)
+	end := s.samplesIn + int64(len(s.held)/channels)
+	tolerance := s.samples(s.timing.Tolerance)
+	switch diff := start - end; {
+	case diff > tolerance:
+		if err := s.releaseHeld(); err != nil {
+			return err
+		}
+		if err := s.sendSilence(int(diff)); err != nil {
+			return fmt.Errorf("fill gap: %w", err)
+		}
+	case -diff > tolerance:
+		chunk = s.overlap(int(-diff), chunk)
+	}
+
+	s.held = append(s.held, chunk...)
+	keep := min(int(s.samples(s.timing.Crossfade))*channels, len(s.held))
+	if err := s.sendPCM(s.held[:len(s.held)-keep]); err != nil {
+		return err
+	}
+	s.held = append(s.held[:0], s.held[len(s.held)-keep:]...)
+
+	return nil
+}
+
+// StartTime returns the wall-clock time of the start of the current link
+// as set by the first SendPCMChunkAt, or the zero time.
+func (s *Packer) StartTime() time.Time {
+	if !s.timed {
+		return time.Time{}
+	}
+	return s.startTime
+}
+
+// overlap resolves n samples per channel at the start of chunk that
+// overlap the input so far and returns what remains of chunk. Samples that
+// overlap held back ones are crossfaded into them, the others are
+// dropped.
+func (s *Packer) overlap(n int, chunk []int16) []int16 {
+	channels := s.opusEncoder.Channels()
+	held := len(s.held) / channels
+
+	drop := min(max(n-held, 0), len(chunk)/channels)
+	chunk = chunk[drop*channels:]
+	n -= drop
+	k := min(n, len(chunk)/channels)
+	if s.timing.Crossfade > 0 {
+		faded := s.held[(held-n)*channels:]
+		for i := 0; i < k; i++ {
+			w := float64(i+1) / float64(n+1)
+			for ch := 0; ch < channels; ch++ {
+				v := float64(faded[i*channels+ch])*(1-w) + float64(chunk[i*channels+ch])*w
+				faded[i*channels+ch] = int16(math.Round(v))
+			}
+		}
+	}
+
+	return chunk[k*channels:]
+}
+
+// releaseHeld sends the samples held back for a crossfade.
+func (s *Packer) releaseHeld() error {
+	if len(s.held) == 0 {
+		return nil
+	}
+	err := s.sendPCM(s.held)
+	s.held = s.held[:0]
+	return err
+}
+
+// samples converts a duration to samples per channel.
+func (s *Packer) samples(d time.Duration) int64 {
+	return int64(math.Round(float64(d) * float64(s.cfg.SampleRate) / float64(time.Second)))
+}
+
+// SendSilence adds d of silence, rounded down to whole samples, for
+// example where the capture device dropped out. Whole frames of it are
+// written as a cached silent packet instead of being encoded, or left out
+// with WithSilenceGaps; granule positions count it either way. With
+// trimming, filters or normalization the silence is processed like any
+// other PCM.
+func (s *Packer) SendSilence(d time.Duration) error {
+	if s.closed {
+		return ErrClosed
+	}
+	if err := s.releaseHeld(); err != nil {
+		return err
+	}
+
+	return s.sendSilence(int(int64(d) * int64(s.cfg.SampleRate) / int64(time.Second)))
+}
+
+// sendSilence adds samples per channel of silence.
+func (s *Packer) sendSilence(samples int) error {
+	channels := s.opusEncoder.Channels()
+	if s.trimmer != nil || len(s.filters) > 0 || s.normalize {
+		for samples > 0 {
+			n := min(samples, s.cfg.SampleRate)
+			if err := s.sendPCM(make([]int16, n*channels)); err != nil {
+				return err
+			}
+			samples -= n
+		}
+		return nil
+	}
+	if samples <= 0 {
+		return nil
+	}
+	s.samplesIn += int64(samples)
+
+	// The silence up to the next frame boundary and the last frame of it
+	// are encoded, so the encoder continues from silence like decoders.
+	frameSize := opus.FrameSizeSamples(s.opusEncoder.Config()) / channels
+	head := 0
+	if buffered := len(s.pcmBuffer) / channels; buffered > 0 {
+		head = min(samples, frameSize-buffered)
+	}
+	frames := (samples - head) / frameSize
+	tail := samples - head - frames*frameSize
+	if frames > 0 {
+		frames--
+		tail += frameSize
+	}
+
+	if err := s.encode(make([]int16, head*channels)); err != nil {
+		return err
+	}
+	if err := s.silentFrames(frames); err != nil {
+		return err
+	}
+	return s.encode(make([]int16, tail*channels))
+}
+
+// silentFrames adds whole frames of silence without encoding them. The
+// loudness meter is skipped, its gates would ignore the silence anyway.
+func (s *Packer) silentFrames(frames int) error {
+	if frames == 0 {
+		return nil
+	}
+
+	frameSize := opus.FrameSizeSamples(s.opusEncoder.Config())
+	zeros := make([]int16, frameSize)
+	// A gap before the first packet would be a start offset instead.
+	if s.silenceGaps && s.measured > 0 {
+		for i := 0; i < frames; i++ {
+			if s.peaks != nil {
+				s.peaks.Write(zeros)
+			}
+		}
+		samples := frames * frameSize / s.opusEncoder.Channels()
+		s.measured += int64(samples)
+		s.oggPacker.AddGap(samples)
+		return nil
+	}
+
+	packet, err := s.opusEncoder.SilentPacket()
+	if err != nil {
+		return fmt.Errorf("encode silence: %w", err)
+	}
+	for i := 0; i < frames; i++ {
+		if s.peaks != nil {
+			s.peaks.Write(zeros)
+		}
+		s.measure(zeros)
+		if err := s.oggPacker.AddChunk(packet, false, frameSize); err != nil {
+			return fmt.Errorf("add chunk: %w", err)
+		}
+	}
+
+	if err := s.writePages(); err != nil {
+		return fmt.Errorf("write pages: %w", err)
+	}
+
+	return nil
+}
+
+// runFilters passes PCM through the filters from the given one on.
+func (s *Packer) runFilters(pcm []int16, from int) []int16 {
+	for _, f := range s.filters[from:] {
+		pcm = f.Process(pcm)
+	}
+	return pcm
+}
+
+// Segments returns the speech detected in the current link so far, with
+// times relative to its input before trimming. It is empty without
+// WithSilenceTrimming.
+func (s *Packer) Segments() []vad.Segment {
+	if s.trimmer == nil {
+		return nil
+	}
+	return s.trimmer.Segments()
+}
+
+// samplesOut returns the number of samples per channel sent to the current
+// link that are kept in the output.
+func (s *Packer) samplesOut() int64 {
+	if s.trimmer == nil {
+		return s.samplesIn
+	}
+	return s.samplesIn - int64(s.trimmer.Removed())
+}
+
+// normalizeAndEncode passes PCM on to normalization, if enabled, and to
+// the encoders.
+func (s *Packer) normalizeAndEncode(chunk []int16) error {
+	switch {
+	case s.normalizer != nil:
+		chunk = s.normalizer.Process(chunk)
+	case s.normalize:
+		s.unnormalized = append(s.unnormalized, chunk...)
+		return nil
+	}
+
+	return s.encode(chunk)
+}
+
+// encode passes PCM on to the encoders and writes the completed pages.
+func (s *Packer) encode(chunk []int16) error {
+	s.meter.Write(chunk)
+	if s.peaks != nil {
+		s.peaks.Write(chunk)
+	}
 	s.pcmBuffer = append(s.pcmBuffer, chunk...)
 	currentOpusPackets, pos, err := s.opusEncoder.Encode(s.pcmBuffer)
 	if err != nil {
 		return fmt.Errorf("encode: %w", err)
 	}
+	s.measure(s.pcmBuffer[:pos])
 
 	s.pcmBuffer = s.pcmBuffer[pos:]
 	for _, opusPacket := range currentOpusPackets {
-		if err := s.oggPacker.AddChunk(opusPacket, false, pos); err != nil {
+		if err := s.oggPacker.AddChunk(opusPacket, false, pos/len(currentOpusPackets)); err != nil {
 			return fmt.Errorf("add chunk: %w", err)
 		}
 	}
+
+	if err := s.writePages(); err != nil {
+		return fmt.Errorf("write pages: %w", err)
+	}
+
 	return nil
 }
 
 func (s *Packer) GetResult() ([]byte, error) {
+	if s.w != nil {
+		return nil, ErrStreaming
+	}
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	if err := s.endLink(); err != nil {
+		return nil, err
+	}
+
+	return s.result, nil
+}
+
+// Close finishes output started with WithWriter: it encodes the buffered
+// PCM, writes the EOS page and, when the writer is seekable, rewrites the
+// header pages with final information. The writer itself is not closed.
+func (s *Packer) Close() error {
+	if s.w == nil {
+		return ErrNoWriter
+	}
+	if s.closed {
+		return ErrClosed
+	}
+
+	return s.endLink()
+}
+
+// endLink terminates the current logical stream and hands its remaining
+// pages to the output.
+func (s *Packer) endLink() error {
 	defer s.oggPacker.Close()
 
+	if err := s.finish(); err != nil {
+		return err
+	}
+	if s.outputGain {
+		s.oggPacker.SetOutputGain(loudness.Q78(s.meter.Gain()))
+	}
+
+	if s.w != nil {
+		if err := s.writePages(); err != nil {
+			return fmt.Errorf("write pages: %w", err)
+		}
+
+		if s.seekable() {
+			if err := s.patchHeader(); err != nil {
+				return fmt.Errorf("patch header pages: %w", err)
+			}
+		}
+
+		return nil
+	}
+
+	oggPages, err := s.oggPacker.ReadPages()
+	if err != nil {
+		return fmt.Errorf("read pages: %w", err)
+	}
+
+	// Everything is still in memory, so the header pages can simply be
+	// replaced by ones of any size.
+	if len(s.finalTags) > 0 || len(s.chapters) > 0 || s.trackGain || s.outputGain {
+		header, err := s.oggPacker.HeaderPages(s.headerTags())
+		if err != nil {
+			return fmt.Errorf("render header pages: %w", err)
+		}
+		old, rest := oggPages[:s.oggPacker.HeaderSize()], oggPages[s.oggPacker.HeaderSize():]
+		oggPages = append(header, rest...)
+		// Longer comments may take more pages than the old header, so the
+		// pages after it move up.
+		if delta := uint32(ogg.CountPages(header) - ogg.CountPages(old)); delta != 0 {
+			if err := ogg.RenumberPages(oggPages[len(header):], delta); err != nil {
+				return fmt.Errorf("renumber pages: %w", err)
+			}
+		}
+	}
+
+	if s.result == nil {
+		s.result = oggPages
+	} else {
+		s.result = append(s.result, oggPages...)
+	}
+
+	return nil
+}
+
+// finish encodes the remaining PCM and terminates the logical stream.
+func (s *Packer) finish() error {
+	s.closed = true
+
+	if err := s.releaseHeld(); err != nil {
+		return fmt.Errorf("release crossfade: %w", err)
+	}
+	if s.trimmer != nil {
+		if err := s.normalizeAndEncode(s.runFilters(s.trimmer.Flush(), 0)); err != nil {
+			return fmt.Errorf("flush silence trimming: %w", err)
+		}
+	}
+	for i, f := range s.filters {
+		if err := s.normalizeAndEncode(s.runFilters(f.Flush(), i+1)); err != nil {
+			return fmt.Errorf("flush filters: %w", err)
+		}
+	}
+	if err := s.flushNormalization(); err != nil {
+		return fmt.Errorf("flush normalization: %w", err)
+	}
+
+	if s.trimEnd {
+		return s.finishTrimmed()
+	}
+
 	if err := s.flushPCMBuffer(); err != nil {
-		return nil, fmt.Errorf("flush buffer: %w", err)
+		return fmt.Errorf("flush buffer: %w", err)
 	}
 
 	// Insert a skeleton track packet with the total duration before finalizing.
 	if dur := s.oggPacker.Duration(); dur > 0 {
 		if err := s.oggPacker.AddSkeleton(dur); err != nil {
-			return nil, fmt.Errorf("add skeleton packet: %w", err)
+			return fmt.Errorf("add skeleton packet: %w", err)
 		}
 	}
 
 	// Now write EOS for the stream (use an empty packet with samplesCount=0).
 	if err := s.oggPacker.AddChunk([]byte{}, true, 0); err != nil {
-		return nil, fmt.Errorf("write eos packet: %w", err)
+		return fmt.Errorf("write eos packet: %w", err)
 	}
 
-	oggPages, err := s.oggPacker.ReadPages()
+	return nil
+}
+
+// flushNormalization encodes the PCM held back for normalization.
+func (s *Packer) flushNormalization() error {
+	switch {
+	case s.normalizer != nil:
+		return s.encode(s.normalizer.Flush())
+	case s.normalize:
+		pcm := loudness.Normalize(s.unnormalized, s.cfg.SampleRate, s.cfg.NumChannels, s.normTarget, s.normCeiling)
+		s.unnormalized = nil
+		return s.encode(pcm)
+	}
+
+	return nil
+}
+
+// finishTrimmed encodes the remaining PCM followed by enough silence to
+// push the encoder lookahead out, and ends the stream on the last audio
+// packet with a granule position that cuts the decoded output exactly
+// after the last sample sent.
+func (s *Packer) finishTrimmed() error {
+	lookahead := s.opusEncoder.Lookahead()
+	s.measure(s.pcmBuffer)
+	pcm := append(s.pcmBuffer, make([]int16, lookahead*s.opusEncoder.Channels())...)
+	s.pcmBuffer = s.pcmBuffer[:0]
+
+	opusPackets, pos, err := s.opusEncoder.Encode(pcm)
 	if err != nil {
-		return nil, fmt.Errorf("read pages: %w", err)
+		return fmt.Errorf("encode: %w", err)
+	}
+	if pos < len(pcm) {
+		lastPackets, err := s.opusEncoder.EncodeWithPadding(pcm[pos:])
+		if err != nil {
+			return fmt.Errorf("encode: %w", err)
+		}
+		opusPackets = append(opusPackets, lastPackets...)
 	}
 
-	s.result = oggPages
+	frameSize := opus.FrameSizeSamples(s.opusEncoder.Config())
+	for _, opusPacket := range opusPackets[:len(opusPackets)-1] {
+		if err := s.oggPacker.AddChunk(opusPacket, false, frameSize); err != nil {
+			return fmt.Errorf("add chunk: %w", err)
+		}
+	}
 
-	return s.result, nil
+	end := s.oggPacker.Granules(lookahead+s.latency) + s.oggPacker.Granules(int(s.samplesOut()))
+	if err := s.oggPacker.AddChunkWithGranule(opusPackets[len(opusPackets)-1], true, end); err != nil {
+		return fmt.Errorf("write eos packet: %w", err)
+	}
+
+	return nil
+}
+
+// headerTags returns the comment header with all information that is only
+// known once encoding has finished.
+func (s *Packer) headerTags() ogg.Tags {
+	tags := s.tags
+	tags.Comments = append([]string(nil), s.tags.Comments...)
+	for i := 0; i < len(s.finalTags); i += 2 {
+		tags.Set(s.finalTags[i], s.finalTags[i+1])
+	}
+	if len(s.chapters) > 0 {
+		tags.SetChapters(s.chapters)
+	}
+	if s.trackGain {
+		gain := loudness.Q78(s.meter.Gain())
+		if s.outputGain {
+			gain = 0
+		}
+		tags.Set("R128_TRACK_GAIN", strconv.Itoa(int(gain)))
+	}
+	tags.Set("DURATION", ogg.FormatTimestamp(s.oggPacker.Duration()))
+
+	return tags
+}
+
+// patchHeader overwrites the header pages at the start of the current
+// chain link.
+func (s *Packer) patchHeader() error {
+	header, err := s.oggPacker.PatchedHeaderPages(s.headerTags())
+	if err != nil {
+		return fmt.Errorf("render header pages: %w", err)
+	}
+
+	if wa, ok := s.w.(io.WriterAt); ok {
+		if _, err := wa.WriteAt(header, s.linkStart); err != nil {
+			return fmt.Errorf("write header pages: %w", err)
+		}
+		return nil
+	}
+
+	ws := s.w.(io.WriteSeeker)
+	end, err := ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("get output position: %w", err)
+	}
+	if _, err := ws.Seek(s.start+s.linkStart, io.SeekStart); err != nil {
+		return fmt.Errorf("seek to header pages: %w", err)
+	}
+	if _, err := ws.Write(header); err != nil {
+		return fmt.Errorf("write header pages: %w", err)
+	}
+	if _, err := ws.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("seek to end of output: %w", err)
+	}
+
+	return nil
+}
+
+// seekable reports whether the header pages can be rewritten after they
+// have been written to the output.
+func (s *Packer) seekable() bool {
+	_, ok := s.w.(io.WriteSeeker)
+	return ok || s.writerAt()
+}
+
+func (s *Packer) writerAt() bool {
+	_, ok := s.w.(io.WriterAt)
+	return ok
+}
+
+// writePages passes completed pages on to the output writer, if any.
+func (s *Packer) writePages() error {
+	if s.w == nil {
+		return nil
+	}
+	n, err := s.oggPacker.WritePagesTo(s.w)
+	s.written += n
+	return err
 }
 
 func (s *Packer) flushPCMBuffer() error {
@@ -90,6 +1037,7 @@ func (s *Packer) flushPCMBuffer() error {
 	if err != nil {
 		return fmt.Errorf("encode: %w", err)
 	}
+	s.measure(s.pcmBuffer)
 
 	for _, opusPacket := range opusPackets {
 		if err := s.oggPacker.AddChunk(opusPacket, false, -1); err != nil {
@@ -99,3 +1047,21 @@ func (s *Packer) flushPCMBuffer() error {
 
 	return nil
 }
+
+// measure passes encoded PCM frame by frame to the level meter. A short
+// last frame is measured without the padding the encoder adds.
+func (s *Packer) measure(pcm []int16) {
+	frameSize := opus.FrameSizeSamples(s.opusEncoder.Config())
+	channels := s.opusEncoder.Channels()
+	for pos := 0; pos < len(pcm); pos += frameSize {
+		frame := pcm[pos:min(pos+frameSize, len(pcm))]
+		levels := s.levels.Frame(frame)
+		if s.onFrame != nil {
+			s.onFrame(FrameStats{
+				Time:     time.Duration(s.measured) * time.Second / time.Duration(s.cfg.SampleRate),
+				Channels: levels,
+			})
+		}
+		s.measured += int64(len(frame) / channels)
+	}
+}
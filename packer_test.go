@@ -3,17 +3,27 @@ package packer_test
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	extopus "gopkg.in/hraban/opus.v2"
 	extogg "mccoy.space/g/ogg"
 
 	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/filter"
+	"github.com/paveldroo/go-ogg-packer/loudness"
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
 	"github.com/paveldroo/go-ogg-packer/opus"
+	"github.com/paveldroo/go-ogg-packer/vad"
 )
 
 const (
@@ -91,6 +101,33 @@ func TestPacker(t *testing.T) {
 	}
 }
 
+func TestPackerGranules(t *testing.T) {
+	p, err := packer.New()
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+
+	// Three frames at once become three packets of 60 ms each.
+	frame := opus.FrameSize * opus.SampleRate / 1000
+	if err := p.SendPCMChunk(make([]int16, 3*frame)); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	audioData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	pages := oggPages(t, audioData)
+	if len(pages) < 5 {
+		t.Fatalf("pages count should be at least 5, current %d", len(pages))
+	}
+	for i, page := range pages[2:5] {
+		if want := int64((i + 1) * frame); page.Granule != want {
+			t.Fatalf("granule position of packet %d should be equal %d, current %d", i, want, page.Granule)
+		}
+	}
+}
+
 func pcmData(t *testing.T, fn string) []int16 {
 	t.Helper()
 
@@ -181,3 +218,654 @@ func genNewRef(t *testing.T, refFileName string, pcmData []int16) {
 
 	fmt.Printf("New reference file %s successfully generated\n", refFileName)
 }
+
+func TestPackerSeekableWriter(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	tests := []struct {
+		name     string
+		seekable bool
+	}{
+		{
+			name:     "write seeker",
+			seekable: true,
+		},
+		{
+			name:     "plain writer",
+			seekable: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &seekBuffer{}
+			var w io.Writer = ws
+			if !tt.seekable {
+				w = &ws.data
+			}
+
+			p, err := packer.New(packer.WithWriter(w))
+			if err != nil {
+				t.Fatalf("create new packer: %s", err.Error())
+			}
+
+			err = p.SetTag("TITLE", "meeting")
+			if tt.seekable && err != nil {
+				t.Fatalf("set tag: %s", err.Error())
+			}
+			if !tt.seekable && err != packer.ErrNotSeekable {
+				t.Fatalf("set tag error should be %v, current %v", packer.ErrNotSeekable, err)
+			}
+
+			if err := p.SendPCMChunk(sourcePCMData); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+			if err := p.Close(); err != nil {
+				t.Fatalf("close packer: %s", err.Error())
+			}
+
+			pages := oggPages(t, ws.data.Bytes())
+			if pages[len(pages)-1].Type&extogg.EOS == 0 {
+				t.Fatal("last page should have the EOS flag")
+			}
+
+			tags := string(pages[1].Packets[0])
+			wantTags := tt.seekable
+			if hasTags := strings.Contains(tags, "TITLE=meeting") && strings.Contains(tags, "DURATION="); hasTags != wantTags {
+				t.Fatalf("final tags written should be %t, tags %q", wantTags, tags)
+			}
+		})
+	}
+}
+
+func TestPackerSetTag(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	p, err := packer.New()
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+
+	if err := p.SendPCMChunk(sourcePCMData); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	if err := p.SetTag("TITLE", "meeting"); err != nil {
+		t.Fatalf("set tag: %s", err.Error())
+	}
+
+	audioData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	pages := oggPages(t, audioData)
+	if tags := string(pages[1].Packets[0]); !strings.Contains(tags, "TITLE=meeting") {
+		t.Fatalf("tags should contain the title, current %q", tags)
+	}
+	if len(pcmFromOgg(t, audioData)) == 0 {
+		t.Fatal("result should contain audio")
+	}
+}
+
+func TestPackerSetTagLargeHeader(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	p, err := packer.New()
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+
+	if err := p.SendPCMChunk(sourcePCMData); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	// The final comments no longer fit into one page.
+	if err := p.SetTag("BIG", strings.Repeat("x", 64900)); err != nil {
+		t.Fatalf("set tag: %s", err.Error())
+	}
+	if err := p.SetTag("EXTRA", strings.Repeat("y", 1000)); err != nil {
+		t.Fatalf("set tag: %s", err.Error())
+	}
+
+	audioData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	for i, b := uint32(0), audioData; len(b) > 0; i++ {
+		if seq := binary.LittleEndian.Uint32(b[18:22]); seq != i {
+			t.Fatalf("page sequence should be equal %d, current %d", i, seq)
+		}
+		size := 27 + int(b[26])
+		for _, s := range b[27:size] {
+			size += int(s)
+		}
+		b = b[size:]
+	}
+
+	var header []byte
+	for _, page := range oggPages(t, audioData)[1:] {
+		if page.Granule > 0 {
+			break
+		}
+		for _, packet := range page.Packets {
+			header = append(header, packet...)
+		}
+	}
+	if tags := string(header); !strings.Contains(tags, "EXTRA=yyy") {
+		t.Fatal("tags should contain the extra tag")
+	}
+	if len(pcmFromOgg(t, audioData)) == 0 {
+		t.Fatal("result should contain audio")
+	}
+}
+
+func TestPackerChapters(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	p, err := packer.New()
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+
+	if err := p.AddChapter("Welcome"); err != nil {
+		t.Fatalf("add chapter: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData[:72000]); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	if err := p.AddChapter("Agenda"); err != nil {
+		t.Fatalf("add chapter: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData[72000:]); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+
+	audioData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	info, err := oggopus.Probe(bytes.NewReader(audioData))
+	if err != nil {
+		t.Fatalf("probe: %s", err.Error())
+	}
+	want := []ogg.Chapter{{Start: 0, Name: "Welcome"}, {Start: 1500 * time.Millisecond, Name: "Agenda"}}
+	if got := info.Links[0].Chapters; !reflect.DeepEqual(got, want) {
+		t.Fatalf("chapters should be equal %v, current %v", want, got)
+	}
+
+	streaming, err := packer.New(packer.WithWriter(io.Discard))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := streaming.AddChapter("Welcome"); err != packer.ErrNotSeekable {
+		t.Fatalf("add chapter error should be %v, current %v", packer.ErrNotSeekable, err)
+	}
+}
+
+func TestPackerR128(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	meter := loudness.NewMeter(48000, 1)
+	meter.Write(sourcePCMData)
+	gain := loudness.Q78(meter.Gain())
+
+	tests := []struct {
+		name       string
+		opts       []packer.Option
+		trackGain  string
+		outputGain int16
+	}{
+		{
+			name:      "track gain",
+			opts:      []packer.Option{packer.WithR128TrackGain()},
+			trackGain: fmt.Sprint(gain),
+		},
+		{
+			name:       "output gain",
+			opts:       []packer.Option{packer.WithR128TrackGain(), packer.WithR128OutputGain()},
+			trackGain:  "0",
+			outputGain: gain,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := packer.New(tt.opts...)
+			if err != nil {
+				t.Fatalf("create new packer: %s", err.Error())
+			}
+			if err := p.SendPCMChunk(sourcePCMData); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+			if got := p.Loudness(); got != meter.Integrated() {
+				t.Fatalf("loudness should be equal %.2f, current %.2f", meter.Integrated(), got)
+			}
+
+			audioData, err := p.GetResult()
+			if err != nil {
+				t.Fatalf("get result from packer: %s", err.Error())
+			}
+
+			info, err := oggopus.Probe(bytes.NewReader(audioData))
+			if err != nil {
+				t.Fatalf("probe: %s", err.Error())
+			}
+			link := info.Links[0]
+			if got := link.Tags.Get("R128_TRACK_GAIN"); len(got) != 1 || got[0] != tt.trackGain {
+				t.Fatalf("track gain should be equal %s, current %v", tt.trackGain, got)
+			}
+			if link.Head.OutputGain != tt.outputGain {
+				t.Fatalf("output gain should be equal %d, current %d", tt.outputGain, link.Head.OutputGain)
+			}
+		})
+	}
+
+	if _, err := packer.New(packer.WithWriter(io.Discard), packer.WithR128TrackGain()); err != packer.ErrNotSeekable {
+		t.Fatalf("new packer error should be %v, current %v", packer.ErrNotSeekable, err)
+	}
+}
+
+func TestPackerNormalization(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	tests := []struct {
+		name string
+		w    io.Writer
+	}{
+		{name: "buffered"},
+		{name: "streaming", w: io.Discard},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []packer.Option{packer.WithNormalization(-16, -1)}
+			if tt.w != nil {
+				opts = append(opts, packer.WithWriter(tt.w))
+			}
+			p, err := packer.New(opts...)
+			if err != nil {
+				t.Fatalf("create new packer: %s", err.Error())
+			}
+			if err := p.SendPCMChunk(sourcePCMData); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+
+			if tt.w != nil {
+				err = p.Close()
+			} else {
+				_, err = p.GetResult()
+			}
+			if err != nil {
+				t.Fatalf("finish packer: %s", err.Error())
+			}
+
+			if got := p.Loudness(); math.Abs(got+16) > 1 {
+				t.Fatalf("loudness should be close to -16 LUFS, current %.2f", got)
+			}
+		})
+	}
+}
+
+func TestPackerSilenceTrimming(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+	silence := make([]int16, 2*48000)
+	pcm := append(append(append([]int16(nil), silence...), sourcePCMData...), silence...)
+
+	p, err := packer.New(packer.WithSilenceTrimming(vad.TrimConfig{
+		Start:   true,
+		End:     true,
+		Padding: 100 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(pcm); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	audioData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	segments := p.Segments()
+	if len(segments) == 0 || segments[0].Start < 2*time.Second {
+		t.Fatalf("speech should start after the leading silence, current %v", segments)
+	}
+
+	info, err := oggopus.Probe(bytes.NewReader(audioData))
+	if err != nil {
+		t.Fatalf("probe: %s", err.Error())
+	}
+	input := time.Duration(len(pcm)) * time.Second / 48000
+	if info.Duration > input-3500*time.Millisecond {
+		t.Fatalf("duration should be shorter than %v, current %v", input-3500*time.Millisecond, info.Duration)
+	}
+}
+
+func TestPackerFilters(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	delay := &delayFilter{latency: 240}
+	p, err := packer.New(packer.WithFilters(filter.Gain(-6), delay))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	audioData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	if delay.samples != len(sourcePCMData)+delay.latency {
+		t.Fatalf("filter output should be equal %d samples, current %d", len(sourcePCMData)+delay.latency, delay.samples)
+	}
+
+	info, err := oggopus.Probe(bytes.NewReader(audioData))
+	if err != nil {
+		t.Fatalf("probe: %s", err.Error())
+	}
+	if preSkip := info.Links[0].Head.PreSkip; preSkip != 240 {
+		t.Fatalf("pre-skip should be equal 240, current %d", preSkip)
+	}
+	// The last packet is padded to a whole frame.
+	input := time.Duration(len(sourcePCMData)) * time.Second / 48000
+	if d := info.Duration - input; d < 0 || d >= 60*time.Millisecond {
+		t.Fatalf("duration should be close to %v, current %v", input, info.Duration)
+	}
+}
+
+// delayFilter delays mono PCM by latency samples.
+type delayFilter struct {
+	latency int
+	samples int // samples returned
+	buf     []int16
+}
+
+func (f *delayFilter) Process(pcm []int16) []int16 {
+	if f.buf == nil {
+		f.buf = make([]int16, f.latency)
+	}
+	f.buf = append(f.buf, pcm...)
+	out := f.buf[:len(pcm)]
+	f.buf = append([]int16(nil), f.buf[len(pcm):]...)
+	f.samples += len(out)
+	return out
+}
+
+func (f *delayFilter) Flush() []int16 {
+	out := f.buf
+	f.buf = nil
+	f.samples += len(out)
+	return out
+}
+
+func (f *delayFilter) Latency() int {
+	return f.latency
+}
+
+// seekBuffer is an in-memory io.WriteSeeker which is not an io.WriterAt.
+type seekBuffer struct {
+	data bytes.Buffer
+	pos  int
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	buf := b.data.Bytes()
+	n := copy(buf[b.pos:], p)
+	b.data.Write(p[n:])
+	b.pos += len(p)
+	return len(p), nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		b.pos = int(offset)
+	case io.SeekCurrent:
+		b.pos += int(offset)
+	case io.SeekEnd:
+		b.pos = b.data.Len() + int(offset)
+	}
+	return int64(b.pos), nil
+}
+
+func TestPackerFrameStats(t *testing.T) {
+	sourcePCMData := pcmData(t, fmt.Sprintf("testdata/%s.pcm", fileBasePath))
+
+	var frames []packer.FrameStats
+	p, err := packer.New(packer.WithFrameStats(func(f packer.FrameStats) {
+		f.Channels = append([]loudness.Levels(nil), f.Channels...)
+		frames = append(frames, f)
+	}))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	if _, err := p.GetResult(); err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	frameSize := 48000 * 60 / 1000
+	if want := (len(sourcePCMData) + frameSize - 1) / frameSize; len(frames) != want {
+		t.Fatalf("frames should be equal %d, current %d", want, len(frames))
+	}
+	meter := loudness.NewLevelMeter(1)
+	for i, f := range frames {
+		if want := time.Duration(i) * 60 * time.Millisecond; f.Time != want {
+			t.Fatalf("frame time should be equal %v, current %v", want, f.Time)
+		}
+		end := min((i+1)*frameSize, len(sourcePCMData))
+		want := meter.Frame(sourcePCMData[i*frameSize : end])[0]
+		if f.Channels[0] != want {
+			t.Fatalf("frame %d levels should be equal %+v, current %+v", i, want, f.Channels[0])
+		}
+	}
+
+	levels := p.Levels()
+	if want := meter.Summary(); levels != want {
+		t.Fatalf("levels should be equal %+v, current %+v", want, levels)
+	}
+	if levels.Samples != int64(len(sourcePCMData)) {
+		t.Fatalf("samples should be equal %d, current %d", len(sourcePCMData), levels.Samples)
+	}
+}
+
+func TestPackerOptionError(t *testing.T) {
+	// The first failing option is reported.
+	_, err := packer.New(packer.WithPicture(ogg.Picture{Data: []byte("not an image")}), packer.WithWaveform(0))
+	if !errors.Is(err, ogg.ErrBadPicture) {
+		t.Fatalf("error should be %v, current %v", ogg.ErrBadPicture, err)
+	}
+}
+
+func TestPackerSendSilence(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate int
+		opts       []packer.Option
+		skipped    int // frames left out
+	}{
+		{name: "silent packets", sampleRate: 48000},
+		{name: "silent packets 16k", sampleRate: 16000},
+		{name: "granule gap", sampleRate: 48000, opts: []packer.Option{packer.WithSilenceGaps()}, skipped: 15},
+		{name: "granule gap 16k", sampleRate: 16000, opts: []packer.Option{packer.WithSilenceGaps()}, skipped: 14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := opus.Config{SampleRate: tt.sampleRate, NumChannels: 1, FrameSize: 60 * time.Millisecond}
+			var frames int
+			opts := append([]packer.Option{
+				packer.WithConfig(cfg),
+				packer.WithFrameStats(func(packer.FrameStats) { frames++ }),
+			}, tt.opts...)
+			p, err := packer.New(opts...)
+			if err != nil {
+				t.Fatalf("create new packer: %s", err.Error())
+			}
+
+			silence := tt.sampleRate * 101 / 100
+			if err := p.SendPCMChunk(make([]int16, 1000)); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+			if err := p.SendSilence(1010 * time.Millisecond); err != nil {
+				t.Fatalf("send silence: %s", err.Error())
+			}
+			if err := p.SendPCMChunk(make([]int16, 1500)); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+			audioData, err := p.GetResult()
+			if err != nil {
+				t.Fatalf("get result from packer: %s", err.Error())
+			}
+
+			// The last packet is padded to a whole frame.
+			frameSize := tt.sampleRate * 60 / 1000
+			total := (1000 + silence + 1500 + frameSize - 1) / frameSize
+			want := time.Duration(total) * 60 * time.Millisecond
+
+			info, err := oggopus.Probe(bytes.NewReader(audioData))
+			if err != nil {
+				t.Fatalf("probe: %s", err.Error())
+			}
+			if info.Duration != want {
+				t.Fatalf("duration should be equal %v, current %v", want, info.Duration)
+			}
+
+			r, err := oggopus.NewReader(bytes.NewReader(audioData))
+			if err != nil {
+				t.Fatalf("create reader: %s", err.Error())
+			}
+			decoded := 0
+			buf := make([]int16, 5760)
+			for {
+				n, err := r.Read(buf)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("read: %s", err.Error())
+				}
+				decoded += n
+			}
+			if want := total * 2880; decoded != want {
+				t.Fatalf("decoded samples should be equal %d, current %d", want, decoded)
+			}
+
+			// The silence before the first frame boundary and in the last
+			// frame is always encoded.
+			if want := total - tt.skipped; frames != want {
+				t.Fatalf("frames should be equal %d, current %d", want, frames)
+			}
+		})
+	}
+}
+
+func TestPackerSendPCMChunkAt(t *testing.T) {
+	constant := func(n int, v int16) []int16 {
+		pcm := make([]int16, n)
+		for i := range pcm {
+			pcm[i] = v
+		}
+		return pcm
+	}
+
+	tests := []struct {
+		name   string
+		timing packer.TimestampConfig
+		second time.Duration // timestamp of the second chunk
+		want   []int16
+	}{
+		{
+			name:   "gap",
+			second: 20 * time.Millisecond,
+			want:   append(append(constant(480, 1000), constant(480, 0)...), constant(480, 2000)...),
+		},
+		{
+			name:   "jitter",
+			timing: packer.TimestampConfig{Tolerance: time.Millisecond},
+			second: 10500 * time.Microsecond,
+			want:   append(constant(480, 1000), constant(480, 2000)...),
+		},
+		{
+			name:   "overlap",
+			second: 5 * time.Millisecond,
+			want:   append(constant(480, 1000), constant(240, 2000)...),
+		},
+		{
+			name:   "crossfade",
+			timing: packer.TimestampConfig{Crossfade: 5 * time.Millisecond},
+			second: 5 * time.Millisecond,
+			want: func() []int16 {
+				pcm := append(constant(480, 1000), constant(240, 2000)...)
+				for i := 0; i < 240; i++ {
+					w := float64(i+1) / 241
+					pcm[240+i] = int16(math.Round(1000*(1-w) + 2000*w))
+				}
+				return pcm
+			}(),
+		},
+	}
+
+	epoch := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tap := &tapFilter{}
+			tt.timing.Epoch = epoch
+			p, err := packer.New(packer.WithTimestamps(tt.timing), packer.WithFilters(tap))
+			if err != nil {
+				t.Fatalf("create new packer: %s", err.Error())
+			}
+			if err := p.SendPCMChunkAt(time.Second, constant(480, 1000)); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+			if err := p.SendPCMChunkAt(time.Second+tt.second, constant(480, 2000)); err != nil {
+				t.Fatalf("send PCM chunk: %s", err.Error())
+			}
+			audioData, err := p.GetResult()
+			if err != nil {
+				t.Fatalf("get result from packer: %s", err.Error())
+			}
+
+			if !reflect.DeepEqual(tap.pcm, tt.want) {
+				t.Fatalf("input should be equal %v, current %v", tt.want, tap.pcm)
+			}
+
+			start := epoch.Add(time.Second)
+			if got := p.StartTime(); !got.Equal(start) {
+				t.Fatalf("start time should be equal %v, current %v", start, got)
+			}
+			info, err := oggopus.Probe(bytes.NewReader(audioData))
+			if err != nil {
+				t.Fatalf("probe: %s", err.Error())
+			}
+			want := start.Format(time.RFC3339Nano)
+			if got := info.Links[0].Tags.Get("START_TIME"); len(got) != 1 || got[0] != want {
+				t.Fatalf("start time comment should be equal %s, current %v", want, got)
+			}
+		})
+	}
+}
+
+// tapFilter records the PCM passing through it.
+type tapFilter struct {
+	pcm []int16
+}
+
+func (f *tapFilter) Process(pcm []int16) []int16 {
+	f.pcm = append(f.pcm, pcm...)
+	return pcm
+}
+
+func (f *tapFilter) Flush() []int16 {
+	return nil
+}
+
+func (f *tapFilter) Latency() int {
+	return 0
+}
@@ -0,0 +1,185 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTimeLayout = "20060102-150405"
+
+var ErrNoRotationLimit = errors.New("rotator needs a maximum duration or size")
+
+// RotatorConfig describes how a Rotator cuts its output into files.
+type RotatorConfig struct {
+	// Template is the file name pattern. "{time}" is replaced with the wall
+	// clock time of the first sample in the file, formatted with TimeLayout,
+	// and "{index}" with the number of the file starting at 0. Existing
+	// files are never overwritten: if a name is taken, for example by two
+	// files started within the resolution of TimeLayout, a counter is added
+	// before the extension ("rec.ogg", "rec-1.ogg", ...).
+	Template string
+	// TimeLayout is the time.Format layout for "{time}".
+	// Defaults to "20060102-150405".
+	TimeLayout string
+	// Start is the wall clock time of the first sample. Defaults to the
+	// time NewRotator is called.
+	Start time.Time
+	// MaxDuration starts a new file after this much audio. The cut is
+	// placed exactly on the sample at the limit.
+	MaxDuration time.Duration
+	// MaxSize starts a new file once the current one has grown to at least
+	// this many bytes. The cut is placed after the chunk that crossed it.
+	MaxSize int64
+	// SyncInterval is passed on to the FileSink of every file.
+	SyncInterval time.Duration
+	// Options configure the Packer of every file. The writer and serial
+	// number are set by the Rotator.
+	Options []Option
+}
+
+// Rotator cuts a continuous PCM stream into separate, independently
+// playable Ogg Opus files. Every file starts with its own OpusHead and
+// OpusTags headers and a new serial number, and is trimmed to exactly the
+// samples it was given, so the files play back gapless one after another.
+type Rotator struct {
+	cfg        RotatorConfig
+	channels   int
+	sampleRate int
+
+	packer  *Packer
+	sink    *FileSink
+	index   int
+	serial  uint32
+	elapsed int64 // samples per channel in files before the current one
+	current int64 // samples per channel in the current file
+	files   []string
+}
+
+func NewRotator(cfg RotatorConfig) (*Rotator, error) {
+	if cfg.MaxDuration <= 0 && cfg.MaxSize <= 0 {
+		return nil, ErrNoRotationLimit
+	}
+	if cfg.TimeLayout == "" {
+		cfg.TimeLayout = defaultTimeLayout
+	}
+	if cfg.Start.IsZero() {
+		cfg.Start = time.Now()
+	}
+
+	return &Rotator{cfg: cfg}, nil
+}
+
+func (r *Rotator) SendPCMChunk(chunk []int16) error {
+	for len(chunk) > 0 {
+		if r.packer == nil {
+			if err := r.openFile(); err != nil {
+				return err
+			}
+		}
+
+		n := len(chunk)
+		if r.cfg.MaxDuration > 0 {
+			limit := int64(r.cfg.MaxDuration) * int64(r.sampleRate) / int64(time.Second)
+			n = min(n, int(limit-r.current)*r.channels)
+		}
+
+		if err := r.packer.SendPCMChunk(chunk[:n]); err != nil {
+			return fmt.Errorf("send PCM chunk to %s: %w", r.files[len(r.files)-1], err)
+		}
+		r.current += int64(n / r.channels)
+		chunk = chunk[n:]
+
+		if len(chunk) > 0 || r.limitReached() {
+			if err := r.closeFile(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close finishes the current file.
+func (r *Rotator) Close() error {
+	if r.packer == nil {
+		return nil
+	}
+	return r.closeFile()
+}
+
+// Files returns the names of all files created so far.
+func (r *Rotator) Files() []string {
+	return r.files
+}
+
+func (r *Rotator) limitReached() bool {
+	if r.cfg.MaxSize > 0 && r.sink.Size() >= r.cfg.MaxSize {
+		return true
+	}
+	if r.cfg.MaxDuration > 0 {
+		limit := int64(r.cfg.MaxDuration) * int64(r.sampleRate) / int64(time.Second)
+		return r.current >= limit
+	}
+	return false
+}
+
+func (r *Rotator) openFile() error {
+	// The format is known from the packer of the first file on.
+	start := r.cfg.Start
+	if r.elapsed > 0 {
+		start = start.Add(time.Duration(r.elapsed) * time.Second / time.Duration(r.sampleRate))
+	}
+	fname := strings.NewReplacer(
+		"{time}", start.Format(r.cfg.TimeLayout),
+		"{index}", strconv.Itoa(r.index),
+	).Replace(r.cfg.Template)
+
+	f, fname, err := createNew(fname)
+	if err != nil {
+		return fmt.Errorf("create file sink: %w", err)
+	}
+	sink := newFileSink(f, r.cfg.SyncInterval)
+
+	opts := append(slices.Clone(r.cfg.Options), WithWriter(sink), withExactLength())
+	if r.serial != 0 {
+		opts = append(opts, withSerial(r.serial+1))
+	}
+	p, err := New(opts...)
+	if err != nil {
+		sink.Close()
+		return fmt.Errorf("create packer for %s: %w", fname, err)
+	}
+
+	cfg := p.opusEncoder.Config()
+	r.packer = p
+	r.sink = sink
+	r.channels = cfg.NumChannels
+	r.sampleRate = cfg.SampleRate
+	r.serial = p.oggPacker.Serial()
+	r.files = append(r.files, fname)
+
+	return nil
+}
+
+func (r *Rotator) closeFile() error {
+	fname := r.files[len(r.files)-1]
+	if err := r.packer.Close(); err != nil {
+		r.sink.Close()
+		return fmt.Errorf("close packer for %s: %w", fname, err)
+	}
+	if err := r.sink.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", fname, err)
+	}
+
+	r.packer = nil
+	r.sink = nil
+	r.index++
+	r.elapsed += r.current
+	r.current = 0
+
+	return nil
+}
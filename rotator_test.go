@@ -0,0 +1,133 @@
+package packer_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	extogg "mccoy.space/g/ogg"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+)
+
+func TestRotator(t *testing.T) {
+	sourcePCMData := pcmData(t, "testdata/48k_1ch.pcm")
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		template    string
+		maxDuration time.Duration
+		maxSize     int64
+		wantFiles   []string
+	}{
+		{
+			name:        "by duration",
+			maxDuration: 1500 * time.Millisecond,
+			wantFiles:   []string{"rec-100000-0.ogg", "rec-100001-1.ogg", "rec-100003-2.ogg"},
+		},
+		{
+			name:      "by size",
+			maxSize:   1,
+			wantFiles: []string{"rec-100000-0.ogg", "rec-100001-1.ogg", "rec-100002-2.ogg"},
+		},
+		{
+			// All files start within the same minute.
+			name:      "name collision",
+			template:  "rec-{time}.ogg",
+			maxSize:   1,
+			wantFiles: []string{"rec-1000.ogg", "rec-1000-1.ogg", "rec-1000-2.ogg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, layout := tt.template, "1504"
+			if template == "" {
+				template, layout = "rec-{time}-{index}.ogg", "150405"
+			}
+			dir := t.TempDir()
+			r, err := packer.NewRotator(packer.RotatorConfig{
+				Template:    filepath.Join(dir, template),
+				TimeLayout:  layout,
+				Start:       start,
+				MaxDuration: tt.maxDuration,
+				MaxSize:     tt.maxSize,
+			})
+			if err != nil {
+				t.Fatalf("create rotator: %s", err.Error())
+			}
+
+			// Three chunks, each larger than the size limit.
+			chunkSize := 64000
+			for i := 0; i < 3*chunkSize; i += chunkSize {
+				if err := r.SendPCMChunk(sourcePCMData[i : i+chunkSize]); err != nil {
+					t.Fatalf("send PCM chunk: %s", err.Error())
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("close rotator: %s", err.Error())
+			}
+
+			files := r.Files()
+			if len(files) != len(tt.wantFiles) {
+				t.Fatalf("files count should be equal %d, current %d", len(tt.wantFiles), len(files))
+			}
+
+			serials := map[uint32]bool{}
+			var samples int64
+			for i, fname := range files {
+				if filepath.Base(fname) != tt.wantFiles[i] {
+					t.Fatalf("file name should be %s, current %s", tt.wantFiles[i], filepath.Base(fname))
+				}
+
+				pages := oggPages(t, readFile(t, fname))
+				if pages[0].Type&extogg.BOS == 0 || pages[len(pages)-1].Type&extogg.EOS == 0 {
+					t.Fatalf("%s should be a complete logical stream", fname)
+				}
+				serials[pages[0].Serial] = true
+
+				head := pages[0].Packets[0]
+				preSkip := int64(head[10]) | int64(head[11])<<8
+				samples += pages[len(pages)-1].Granule - preSkip
+			}
+
+			if len(serials) != len(files) {
+				t.Fatal("every file should have its own serial number")
+			}
+			if samples != int64(3*chunkSize) {
+				t.Fatalf("files should hold %d samples in total, current %d", 3*chunkSize, samples)
+			}
+		})
+	}
+}
+
+func TestRotatorOptions(t *testing.T) {
+	sourcePCMData := pcmData(t, "testdata/48k_1ch.pcm")
+
+	dir := t.TempDir()
+	r, err := packer.NewRotator(packer.RotatorConfig{
+		Template:    filepath.Join(dir, "rec-{index}.ogg"),
+		MaxDuration: time.Second,
+		Options:     []packer.Option{packer.WithHeaderReserve(4000)},
+	})
+	if err != nil {
+		t.Fatalf("create rotator: %s", err.Error())
+	}
+	if err := r.SendPCMChunk(sourcePCMData[:96000]); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("close rotator: %s", err.Error())
+	}
+
+	if len(r.Files()) != 2 {
+		t.Fatalf("files count should be equal 2, current %d", len(r.Files()))
+	}
+	for _, fname := range r.Files() {
+		pages := oggPages(t, readFile(t, fname))
+		if tags := pages[1].Packets[0]; len(tags) < 4000 {
+			t.Fatalf("comment header of %s should take the reserved 4000 bytes, current %d", fname, len(tags))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package rtp records Opus media received over RTP (RFC 3550, with the
+// payload format of RFC 7587), as sent by WebRTC and SIP endpoints, into
+// Ogg Opus files without transcoding.
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	version    = 2
+	headerSize = 12
+)
+
+var (
+	ErrShortPacket = errors.New("rtp: packet too short")
+	ErrVersion     = errors.New("rtp: unsupported version")
+	ErrPadding     = errors.New("rtp: invalid padding")
+)
+
+// Packet is a parsed RTP packet. The payload of an Opus packet is exactly
+// one Opus packet (RFC 7587, section 4.2).
+type Packet struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRC           []uint32
+	Payload        []byte
+}
+
+// Parse parses an RTP packet as described in RFC 3550, section 5.1. A
+// header extension is skipped and padding removed. Payload and CSRC refer
+// to b.
+func Parse(b []byte) (Packet, error) {
+	if len(b) < headerSize {
+		return Packet{}, ErrShortPacket
+	}
+	if b[0]>>6 != version {
+		return Packet{}, ErrVersion
+	}
+
+	p := Packet{
+		Marker:         b[1]&0x80 != 0,
+		PayloadType:    b[1] & 0x7f,
+		SequenceNumber: binary.BigEndian.Uint16(b[2:]),
+		Timestamp:      binary.BigEndian.Uint32(b[4:]),
+		SSRC:           binary.BigEndian.Uint32(b[8:]),
+	}
+
+	pos := headerSize
+	csrcs := int(b[0] & 0x0f)
+	if len(b) < pos+4*csrcs {
+		return Packet{}, ErrShortPacket
+	}
+	for i := 0; i < csrcs; i++ {
+		p.CSRC = append(p.CSRC, binary.BigEndian.Uint32(b[pos:]))
+		pos += 4
+	}
+
+	if b[0]&0x10 != 0 {
+		if len(b) < pos+4 {
+			return Packet{}, ErrShortPacket
+		}
+		pos += 4 + 4*int(binary.BigEndian.Uint16(b[pos+2:]))
+		if len(b) < pos {
+			return Packet{}, ErrShortPacket
+		}
+	}
+
+	end := len(b)
+	if b[0]&0x20 != 0 {
+		padding := int(b[end-1])
+		if padding == 0 || end-padding < pos {
+			return Packet{}, ErrPadding
+		}
+		end -= padding
+	}
+	p.Payload = b[pos:end]
+
+	return p, nil
+}
+
+// Marshal encodes the packet without header extension or padding.
+func (p Packet) Marshal() []byte {
+	b := make([]byte, headerSize, headerSize+4*len(p.CSRC)+len(p.Payload))
+	b[0] = version<<6 | byte(len(p.CSRC))
+	b[1] = p.PayloadType & 0x7f
+	if p.Marker {
+		b[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[2:], p.SequenceNumber)
+	binary.BigEndian.PutUint32(b[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[8:], p.SSRC)
+	for _, c := range p.CSRC {
+		b = binary.BigEndian.AppendUint32(b, c)
+	}
+	return append(b, p.Payload...)
+}
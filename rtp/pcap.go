@@ -0,0 +1,210 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// Link types of the captures PcapReader understands.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+)
+
+const (
+	pcapHeaderSize   = 24
+	recordHeaderSize = 16
+	protocolUDP      = 17
+)
+
+var (
+	ErrPcapFormat = errors.New("rtp: not a pcap file")
+	ErrLinkType   = errors.New("rtp: unsupported pcap link type")
+)
+
+// UDPPacket is a UDP datagram read from a capture.
+type UDPPacket struct {
+	Time    time.Time
+	Src     netip.AddrPort
+	Dst     netip.AddrPort
+	Payload []byte
+}
+
+// PcapReader reads the UDP datagrams of a capture in the classic pcap
+// format written by tcpdump and Wireshark, for recording RTP offline.
+// Captures in the pcapng format have to be converted first, for example
+// with "editcap -F pcap". IP fragments and other packets are skipped.
+type PcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint32
+	header   [recordHeaderSize]byte
+	buf      []byte
+}
+
+// NewPcapReader reads the file header of a capture from r.
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	var h [pcapHeaderSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, fmt.Errorf("read pcap header: %w", err)
+	}
+
+	p := &PcapReader{r: r}
+	switch binary.LittleEndian.Uint32(h[:]) {
+	case 0xa1b2c3d4:
+		p.order = binary.LittleEndian
+	case 0xd4c3b2a1:
+		p.order = binary.BigEndian
+	case 0xa1b23c4d:
+		p.order, p.nanos = binary.LittleEndian, true
+	case 0x4d3cb2a1:
+		p.order, p.nanos = binary.BigEndian, true
+	default:
+		return nil, ErrPcapFormat
+	}
+
+	p.linkType = p.order.Uint32(h[20:]) & 0x0fffffff
+	switch p.linkType {
+	case linkNull, linkEthernet, linkRaw, linkLinuxSLL, linkIPv4, linkIPv6:
+	default:
+		return nil, fmt.Errorf("%w %d", ErrLinkType, p.linkType)
+	}
+
+	return p, nil
+}
+
+// Next returns the next UDP datagram of the capture, or io.EOF at its end.
+// The payload is only valid until the next call.
+func (p *PcapReader) Next() (UDPPacket, error) {
+	for {
+		if _, err := io.ReadFull(p.r, p.header[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return UDPPacket{}, fmt.Errorf("read record header: %w", err)
+			}
+			return UDPPacket{}, err
+		}
+
+		size := p.order.Uint32(p.header[8:])
+		if size > 1<<18 {
+			return UDPPacket{}, fmt.Errorf("record of %d bytes: %w", size, ErrPcapFormat)
+		}
+		if cap(p.buf) < int(size) {
+			p.buf = make([]byte, size)
+		}
+		p.buf = p.buf[:size]
+		if _, err := io.ReadFull(p.r, p.buf); err != nil {
+			return UDPPacket{}, fmt.Errorf("read record: %w", io.ErrUnexpectedEOF)
+		}
+
+		u, ok := p.udp(p.buf)
+		if !ok {
+			continue
+		}
+
+		sec := int64(p.order.Uint32(p.header[0:]))
+		frac := int64(p.order.Uint32(p.header[4:]))
+		if !p.nanos {
+			frac *= 1000
+		}
+		u.Time = time.Unix(sec, frac)
+
+		return u, nil
+	}
+}
+
+// udp unwraps the link and IP layers of a frame.
+func (p *PcapReader) udp(b []byte) (UDPPacket, bool) {
+	var ip []byte
+	switch p.linkType {
+	case linkNull:
+		if len(b) < 4 {
+			return UDPPacket{}, false
+		}
+		ip = b[4:]
+	case linkEthernet:
+		if len(b) < 14 {
+			return UDPPacket{}, false
+		}
+		etherType, rest := binary.BigEndian.Uint16(b[12:]), b[14:]
+		for etherType == 0x8100 || etherType == 0x88a8 {
+			if len(rest) < 4 {
+				return UDPPacket{}, false
+			}
+			etherType, rest = binary.BigEndian.Uint16(rest[2:]), rest[4:]
+		}
+		ip = rest
+	case linkLinuxSLL:
+		if len(b) < 16 {
+			return UDPPacket{}, false
+		}
+		ip = b[16:]
+	default:
+		ip = b
+	}
+
+	if len(ip) == 0 {
+		return UDPPacket{}, false
+	}
+	switch ip[0] >> 4 {
+	case 4:
+		return ipv4(ip)
+	case 6:
+		return ipv6(ip)
+	}
+	return UDPPacket{}, false
+}
+
+func ipv4(b []byte) (UDPPacket, bool) {
+	if len(b) < 20 {
+		return UDPPacket{}, false
+	}
+	headerLen := int(b[0]&0x0f) * 4
+	total := int(binary.BigEndian.Uint16(b[2:]))
+	fragment := binary.BigEndian.Uint16(b[6:])
+	if headerLen < 20 || total < headerLen || len(b) < total || b[9] != protocolUDP || fragment&0x3fff != 0 {
+		return UDPPacket{}, false
+	}
+
+	src := netip.AddrFrom4([4]byte(b[12:16]))
+	dst := netip.AddrFrom4([4]byte(b[16:20]))
+	return udp(src, dst, b[headerLen:total])
+}
+
+func ipv6(b []byte) (UDPPacket, bool) {
+	if len(b) < 40 {
+		return UDPPacket{}, false
+	}
+	end := 40 + int(binary.BigEndian.Uint16(b[4:]))
+	if b[6] != protocolUDP || len(b) < end {
+		return UDPPacket{}, false
+	}
+
+	src := netip.AddrFrom16([16]byte(b[8:24]))
+	dst := netip.AddrFrom16([16]byte(b[24:40]))
+	return udp(src, dst, b[40:end])
+}
+
+func udp(src, dst netip.Addr, b []byte) (UDPPacket, bool) {
+	if len(b) < 8 {
+		return UDPPacket{}, false
+	}
+	length := int(binary.BigEndian.Uint16(b[4:]))
+	if length < 8 || len(b) < length {
+		return UDPPacket{}, false
+	}
+
+	return UDPPacket{
+		Src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(b[0:])),
+		Dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(b[2:])),
+		Payload: b[8:length],
+	}, true
+}
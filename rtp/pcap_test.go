@@ -0,0 +1,95 @@
+package rtp_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/rtp"
+)
+
+// frame returns an Ethernet frame with an IPv4 UDP datagram.
+func frame(src, dst netip.AddrPort, payload []byte) []byte {
+	b := make([]byte, 14, 42+len(payload))
+	binary.BigEndian.PutUint16(b[12:], 0x0800)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(28+len(payload)))
+	ip[8] = 64
+	ip[9] = 17
+	copy(ip[12:], src.Addr().AsSlice())
+	copy(ip[16:], dst.Addr().AsSlice())
+	b = append(b, ip...)
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:], src.Port())
+	binary.BigEndian.PutUint16(udp[2:], dst.Port())
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(payload)))
+	b = append(b, udp...)
+
+	return append(b, payload...)
+}
+
+func TestPcapReader(t *testing.T) {
+	src := netip.MustParseAddrPort("192.0.2.1:5004")
+	dst := netip.MustParseAddrPort("192.0.2.2:40000")
+	start := time.Unix(1700000000, 250000000)
+
+	var file bytes.Buffer
+	header := []uint32{0xa1b2c3d4, 0x00040002, 0, 0, 65535, 1}
+	if err := binary.Write(&file, binary.LittleEndian, header); err != nil {
+		t.Fatalf("write header: %s", err.Error())
+	}
+	record := func(at time.Time, b []byte) {
+		h := []uint32{uint32(at.Unix()), uint32(at.Nanosecond() / 1000), uint32(len(b)), uint32(len(b))}
+		if err := binary.Write(&file, binary.LittleEndian, h); err != nil {
+			t.Fatalf("write record: %s", err.Error())
+		}
+		file.Write(b)
+	}
+
+	packets := stream(t, 3, 7, 0, 1)
+	for i, p := range packets {
+		record(start.Add(time.Duration(i)*20*time.Millisecond), frame(src, dst, p.Marshal()))
+		if i == 0 {
+			// An ARP frame is skipped.
+			record(start, make([]byte, 42))
+		}
+	}
+
+	r, err := rtp.NewPcapReader(&file)
+	if err != nil {
+		t.Fatalf("create reader: %s", err.Error())
+	}
+	for i, p := range packets {
+		u, err := r.Next()
+		if err != nil {
+			t.Fatalf("next %d: %s", i, err.Error())
+		}
+		if u.Src != src || u.Dst != dst {
+			t.Fatalf("addresses should be equal %v > %v, current %v > %v", src, dst, u.Src, u.Dst)
+		}
+		if at := start.Add(time.Duration(i) * 20 * time.Millisecond); !u.Time.Equal(at) {
+			t.Fatalf("time should be equal %v, current %v", at, u.Time)
+		}
+		got, err := rtp.Parse(u.Payload)
+		if err != nil {
+			t.Fatalf("parse %d: %s", i, err.Error())
+		}
+		if got.SequenceNumber != p.SequenceNumber || !bytes.Equal(got.Payload, p.Payload) {
+			t.Fatalf("packet %d should be equal %+v, current %+v", i, p, got)
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("next should fail with %v, current %v", io.EOF, err)
+	}
+
+	if _, err := rtp.NewPcapReader(bytes.NewReader(make([]byte, 24))); !errors.Is(err, rtp.ErrPcapFormat) {
+		t.Fatalf("reader error should be equal %v, current %v", rtp.ErrPcapFormat, err)
+	}
+}
@@ -0,0 +1,513 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	extopus "gopkg.in/hraban/opus.v2"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/opus"
+)
+
+const (
+	// clockRate is the RTP clock rate of Opus, whatever the sampling rate
+	// of the audio (RFC 7587, section 4.1).
+	clockRate = 48000
+	// concealFrame is the frame size of concealed audio, 20 ms.
+	concealFrame = clockRate / 50
+	// window is how many sequence numbers before the next expected one
+	// are remembered to tell late packets from duplicates, and how far
+	// ahead of it a packet may be before the sequence counts as restarted.
+	window = 1024
+	// primeSamples is how much decoded audio before a loss is kept to
+	// prime the encoder of the concealed audio, 80 ms.
+	primeSamples = clockRate * 2 / 25
+	// minSequential is how many packets in sequence another SSRC has to
+	// send, with none of the current one in between, before the recorder
+	// switches to it (RFC 3550, appendix A.1).
+	minSequential = 2
+
+	defaultMaxHold = 200 * time.Millisecond
+)
+
+var ErrClosed = errors.New("rtp: recorder is closed")
+
+// Loss selects what fills the place of lost packets.
+type Loss int
+
+const (
+	// Gap leaves lost packets out and lets the granule position jump
+	// over them, which decoders fill with silence.
+	Gap Loss = iota
+	// PLC fills lost packets with the packet loss concealment of the opus
+	// decoder, which continues the preceding audio and fades it out.
+	PLC
+	// FEC restores the last lost packet before a received one from the
+	// forward error correction data the sender put into it, if any, and
+	// conceals the others like PLC.
+	FEC
+)
+
+// Config configures a Recorder.
+type Config struct {
+	// Channels is the channel count of the Ogg stream. Opus packets carry
+	// their own channel count, decoders mix them to this. Defaults to 1.
+	Channels int
+	// PayloadType is the RTP payload type negotiated for Opus. Packets of
+	// other types are ignored. Zero accepts all.
+	PayloadType uint8
+	// SSRC is the source to record. Packets of other sources are
+	// ignored. Zero records the first source and follows changes of it,
+	// see Recorder.
+	SSRC uint32
+	// PreSkip is the encoder delay of the sender in 48 kHz samples. It is
+	// unknown in general and defaults to 0.
+	PreSkip uint16
+	// MaxHold is how long a missing packet is waited for before it is
+	// given up as lost: until as much audio has queued up behind it, or
+	// until the packets behind it have been held that long, see Advance.
+	// Defaults to 200 ms.
+	MaxHold time.Duration
+	Loss    Loss
+	// Now returns the arrival time of packets. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Stats counts the packets a Recorder handled.
+type Stats struct {
+	// Packets were written to the stream.
+	Packets int
+	// Lost packets did not arrive within MaxHold.
+	Lost int
+	// Recovered and Concealed count the 20 ms frames filled from FEC and
+	// with PLC in place of lost packets. The decoder falls back to PLC for
+	// FEC frames when the following packet carries no FEC data.
+	Recovered int
+	Concealed int
+	// Late packets arrived after they were given up and were dropped.
+	Late int
+	// Duplicate packets had already been received and were dropped.
+	Duplicate int
+	// Ignored packets had another payload type or SSRC, a payload that is
+	// not an Opus packet, or came from a source that was not switched to.
+	Ignored int
+	// SSRCChanges counts how often the sender changed its SSRC.
+	SSRCChanges int
+}
+
+// Recorder writes the Opus packets of one RTP stream to an Ogg Opus file.
+// Packets are put back in order by their sequence numbers, which may wrap
+// around, and placed on the timeline by their RTP timestamps: silence the
+// sender left out, for example with DTX, and lost packets become gaps in
+// the granule positions unless they are concealed. When the SSRC changes
+// the new source continues where the previous one ended. The recorder only
+// switches once the new source has sent a few packets in sequence while
+// the current one was silent, so stray packets and the other direction of
+// a call do not take over the recording.
+//
+// Received packets are copied as they are; only concealed audio is
+// encoded. A sender that stops sending leaves the packets behind a missing
+// one queued until Advance is called. It is not safe for concurrent use.
+type Recorder struct {
+	cfg     Config
+	w       io.Writer
+	packer  *ogg.Packer
+	maxHold int64 // 48 kHz samples
+	closed  bool
+
+	started   bool
+	ssrc      uint32
+	probation []Packet // packets in sequence of another SSRC
+
+	next     int64 // extended sequence number of the next packet
+	highest  int64 // highest extended sequence number received
+	queue    map[int64]Packet
+	queued   int64          // 48 kHz samples in queue
+	arrivals []arrival      // queued packets in the order they arrived
+	history  map[int64]bool // whether recent sequence numbers were lost
+	lost     int            // packets given up since the last one written
+
+	timestamp int64 // extended timestamp of the last packet written
+	base      int64 // granule position of extended timestamp 0
+	end       int64 // granule position after the last packet written
+	last      int   // samples of the last packet written
+
+	decoder *extopus.Decoder // keeps the state for concealment
+	pcm     []int16
+	recent  []int16 // the last primeSamples of decoded audio
+	stats   Stats
+}
+
+// NewRecorder writes the headers of an Ogg Opus stream to w and returns a
+// Recorder for its packets.
+func NewRecorder(w io.Writer, cfg Config) (*Recorder, error) {
+	if cfg.Channels == 0 {
+		cfg.Channels = 1
+	}
+	if cfg.MaxHold == 0 {
+		cfg.MaxHold = defaultMaxHold
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	packer, err := ogg.New(uint8(cfg.Channels), clockRate, ogg.WithPreSkip(cfg.PreSkip))
+	if err != nil {
+		return nil, fmt.Errorf("create ogg packer: %w", err)
+	}
+
+	r := &Recorder{
+		cfg:     cfg,
+		w:       w,
+		packer:  packer,
+		maxHold: int64(cfg.MaxHold) * clockRate / int64(time.Second),
+		queue:   make(map[int64]Packet),
+		history: make(map[int64]bool),
+	}
+	if cfg.Loss != Gap {
+		if r.decoder, err = extopus.NewDecoder(clockRate, cfg.Channels); err != nil {
+			return nil, fmt.Errorf("create opus decoder: %w", err)
+		}
+		r.pcm = make([]int16, 5760*cfg.Channels) // 120 ms, the longest packet
+	}
+
+	if err := r.writePages(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// WritePacket parses and records an RTP packet. The packet is copied.
+func (r *Recorder) WritePacket(b []byte) error {
+	p, err := Parse(b)
+	if err != nil {
+		return err
+	}
+	return r.WriteRTP(p)
+}
+
+// WriteRTP records a parsed RTP packet. Its payload is copied.
+func (r *Recorder) WriteRTP(p Packet) error {
+	if r.closed {
+		return ErrClosed
+	}
+	if r.cfg.PayloadType != 0 && p.PayloadType != r.cfg.PayloadType ||
+		r.cfg.SSRC != 0 && p.SSRC != r.cfg.SSRC {
+		r.stats.Ignored++
+		return nil
+	}
+	if _, err := oggopus.PacketSamples(p.Payload); err != nil {
+		r.stats.Ignored++
+		return nil
+	}
+
+	if !r.started {
+		r.start(p)
+	} else if p.SSRC != r.ssrc {
+		return r.probe(p)
+	}
+	// The current source is still sending.
+	r.stats.Ignored += len(r.probation)
+	r.probation = nil
+
+	return r.receive(p)
+}
+
+// probe holds back packets of another SSRC until minSequential of them
+// arrived in sequence, and then switches to it.
+func (r *Recorder) probe(p Packet) error {
+	if n := len(r.probation); n > 0 {
+		prev := r.probation[n-1]
+		if p.SSRC != prev.SSRC || p.SequenceNumber != prev.SequenceNumber+1 {
+			r.stats.Ignored += n
+			r.probation = nil
+		}
+	}
+	p.Payload = append([]byte(nil), p.Payload...)
+	p.CSRC = nil
+	r.probation = append(r.probation, p)
+	if len(r.probation) < minSequential {
+		return nil
+	}
+
+	if err := r.flush(); err != nil {
+		return err
+	}
+	r.stats.SSRCChanges++
+	packets := r.probation
+	r.probation = nil
+	r.start(packets[0])
+	for _, p := range packets {
+		if err := r.receive(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// receive queues a packet of the current source and writes the packets
+// that are due.
+func (r *Recorder) receive(p Packet) error {
+	seq := r.extend(p.SequenceNumber)
+	if seq < r.next {
+		if lost, ok := r.history[seq]; ok && !lost {
+			r.stats.Duplicate++
+		} else {
+			r.stats.Late++
+		}
+		return nil
+	}
+	if seq-r.next >= window {
+		// The sender skipped far ahead, for example after a restart.
+		if err := r.flush(); err != nil {
+			return err
+		}
+		r.next = seq
+	}
+	if _, ok := r.queue[seq]; ok {
+		r.stats.Duplicate++
+		return nil
+	}
+	p.Payload = append([]byte(nil), p.Payload...)
+	p.CSRC = nil
+	now := r.cfg.Now()
+	r.queue[seq] = p
+	samples, _ := oggopus.PacketSamples(p.Payload)
+	r.queued += int64(samples)
+	r.arrivals = append(r.arrivals, arrival{seq: seq, at: now})
+	r.highest = max(r.highest, seq)
+
+	return r.release(now)
+}
+
+// Advance gives up the missing packets that the packets queued behind them
+// have waited for longer than MaxHold at now, and writes those. Call it
+// regularly, for example from a ticker, so that the recording keeps up
+// when the sender stalls.
+func (r *Recorder) Advance(now time.Time) error {
+	if r.closed {
+		return ErrClosed
+	}
+	return r.release(now)
+}
+
+// release writes the packets at the head of the queue, giving up missing
+// ones once MaxHold of audio is queued behind them or has been held for
+// MaxHold at now.
+func (r *Recorder) release(now time.Time) error {
+	for len(r.queue) > 0 {
+		if _, ok := r.queue[r.next]; !ok && r.queued <= r.maxHold && !r.expired(now) {
+			break
+		}
+		if err := r.advance(); err != nil {
+			return err
+		}
+	}
+
+	return r.writePages()
+}
+
+// expired reports whether the oldest queued packet arrived more than
+// MaxHold before now.
+func (r *Recorder) expired(now time.Time) bool {
+	// Packets are released in sequence rather than in the order they
+	// arrived, so released ones are only dropped once they come first.
+	for len(r.arrivals) > 0 && r.arrivals[0].seq < r.next {
+		r.arrivals = r.arrivals[1:]
+	}
+	return len(r.arrivals) > 0 && now.Sub(r.arrivals[0].at) > r.cfg.MaxHold
+}
+
+// Close writes the queued packets and ends the stream. The writer is not
+// closed.
+func (r *Recorder) Close() error {
+	if r.closed {
+		return ErrClosed
+	}
+	r.closed = true
+	defer r.packer.Close()
+	r.stats.Ignored += len(r.probation)
+	r.probation = nil
+
+	if err := r.flush(); err != nil {
+		return err
+	}
+	if err := r.packer.AddChunkWithGranule([]byte{}, true, r.end); err != nil {
+		return fmt.Errorf("write eos packet: %w", err)
+	}
+
+	return r.writePages()
+}
+
+// Stats returns the counts of the packets handled so far.
+func (r *Recorder) Stats() Stats {
+	return r.stats
+}
+
+// Duration returns the duration of the recorded stream.
+func (r *Recorder) Duration() time.Duration {
+	return r.packer.Duration()
+}
+
+// start makes p the first packet of a new source, which continues at the
+// end of the stream so far.
+func (r *Recorder) start(p Packet) {
+	r.started = true
+	r.ssrc = p.SSRC
+	r.next = int64(p.SequenceNumber)
+	r.highest = r.next
+	r.timestamp = int64(p.Timestamp)
+	r.base = r.end - r.timestamp
+	r.lost = 0
+	clear(r.history)
+}
+
+// extend returns the sequence number in a range that does not wrap
+// around, relative to the highest one received.
+func (r *Recorder) extend(seq uint16) int64 {
+	return r.highest + int64(int16(seq-uint16(r.highest)))
+}
+
+// arrival is the time a queued packet arrived.
+type arrival struct {
+	seq int64
+	at  time.Time
+}
+
+// flush writes all queued packets.
+func (r *Recorder) flush() error {
+	for len(r.queue) > 0 {
+		if err := r.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advance writes the next packet, or gives it up if it is missing.
+func (r *Recorder) advance() error {
+	seq := r.next
+	r.next++
+	delete(r.history, seq-window)
+
+	p, ok := r.queue[seq]
+	r.history[seq] = !ok
+	if !ok {
+		r.stats.Lost++
+		r.lost++
+		return nil
+	}
+	delete(r.queue, seq)
+	samples, _ := oggopus.PacketSamples(p.Payload)
+	r.queued -= int64(samples)
+	if len(r.queue) == 0 {
+		// A new source may reuse the sequence numbers.
+		r.arrivals = r.arrivals[:0]
+	}
+
+	r.timestamp += int64(int32(p.Timestamp - uint32(r.timestamp)))
+	start := max(r.base+r.timestamp, r.end)
+	if r.lost > 0 && r.cfg.Loss != Gap {
+		if err := r.conceal(start, p); err != nil {
+			return err
+		}
+	} else if r.decoder != nil {
+		r.decode(p)
+	}
+	r.lost = 0
+
+	r.end = start + int64(samples)
+	r.last = samples
+	if err := r.packer.AddChunkWithGranule(p.Payload, false, r.end); err != nil {
+		return fmt.Errorf("add packet %d: %w", seq, err)
+	}
+	r.stats.Packets++
+
+	return nil
+}
+
+// conceal fills the place of the packets lost before p, which starts at
+// granule position start, with whole frames of concealed audio right
+// before it, and decodes p. Time the lost packets do not account for, like
+// DTX, stays a gap.
+//
+// The concealed audio is encoded by a new encoder for every loss, primed
+// with the audio before it and fed the start of p to flush its lookahead,
+// so that it decodes in place.
+func (r *Recorder) conceal(start int64, p Packet) error {
+	frames := int(min(start-r.end, int64(r.lost*r.last)) / concealFrame)
+	if frames == 0 {
+		r.decode(p)
+		return nil
+	}
+
+	channels := r.cfg.Channels
+	frame := concealFrame * channels
+	pcm := make([]int16, frames*frame)
+	for i := 0; i < frames; i++ {
+		buf := pcm[i*frame : (i+1)*frame]
+		if i == frames-1 && r.cfg.Loss == FEC {
+			if err := r.decoder.DecodeFEC(p.Payload, buf); err == nil {
+				r.stats.Recovered++
+				continue
+			}
+		}
+		if err := r.decoder.DecodePLC(buf); err != nil {
+			return fmt.Errorf("conceal lost packet: %w", err)
+		}
+		r.stats.Concealed++
+	}
+
+	in := append(slices.Clone(r.recent), pcm...)
+	from := len(r.recent) / channels
+	r.remember(pcm)
+	in = append(in, r.decode(p)...)
+
+	cfg := opus.Config{SampleRate: clockRate, NumChannels: channels, FrameSize: 20 * time.Millisecond}
+	packets, err := opus.EncodeSpan(cfg, in, from, from+frames*concealFrame)
+	if err != nil {
+		return fmt.Errorf("encode concealed audio: %w", err)
+	}
+	pos := start - int64(frames*concealFrame)
+	for _, packet := range packets {
+		pos += concealFrame
+		if err := r.packer.AddChunkWithGranule(packet, false, pos); err != nil {
+			return fmt.Errorf("add concealed packet: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// decode decodes p for the state of the decoder and returns its audio.
+func (r *Recorder) decode(p Packet) []int16 {
+	n, err := r.decoder.Decode(p.Payload, r.pcm)
+	if err != nil {
+		return nil
+	}
+	pcm := r.pcm[:n*r.cfg.Channels]
+	r.remember(pcm)
+	return pcm
+}
+
+// remember adds decoded audio to the recent audio.
+func (r *Recorder) remember(pcm []int16) {
+	r.recent = append(r.recent, pcm...)
+	if n := primeSamples * r.cfg.Channels; len(r.recent) > n {
+		r.recent = r.recent[len(r.recent)-n:]
+	}
+}
+
+func (r *Recorder) writePages() error {
+	if _, err := r.packer.WritePagesTo(r.w); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package rtp_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/opus"
+	"github.com/paveldroo/go-ogg-packer/rtp"
+)
+
+func TestParse(t *testing.T) {
+	p := rtp.Packet{
+		Marker:         true,
+		PayloadType:    111,
+		SequenceNumber: 65535,
+		Timestamp:      4294967000,
+		SSRC:           0xdeadbeef,
+		CSRC:           []uint32{1, 2},
+		Payload:        []byte{0xf8, 1, 2, 3},
+	}
+	got, err := rtp.Parse(p.Marshal())
+	if err != nil {
+		t.Fatalf("parse: %s", err.Error())
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("packets should be equal %+v, current %+v", p, got)
+	}
+
+	// One word of header extension and two bytes of padding.
+	b := []byte{0xb0, 111, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0xbe, 0xde, 0, 1, 9, 9, 9, 9, 0xf8, 7, 0, 2}
+	got, err = rtp.Parse(b)
+	if err != nil {
+		t.Fatalf("parse: %s", err.Error())
+	}
+	if want := []byte{0xf8, 7}; !bytes.Equal(got.Payload, want) {
+		t.Fatalf("payload should be equal %v, current %v", want, got.Payload)
+	}
+
+	for _, tc := range []struct {
+		b   []byte
+		err error
+	}{
+		{b[:8], rtp.ErrShortPacket},
+		{b[:14], rtp.ErrShortPacket},
+		{append([]byte{0x40}, b[1:]...), rtp.ErrVersion},
+		{append(b[:len(b)-1:len(b)-1], 30), rtp.ErrPadding},
+	} {
+		if _, err := rtp.Parse(tc.b); !errors.Is(err, tc.err) {
+			t.Fatalf("parse error should be equal %v, current %v", tc.err, err)
+		}
+	}
+}
+
+// stream returns n RTP packets of 20 ms starting at seq and ts.
+func stream(t *testing.T, n int, seq uint16, ts, ssrc uint32) []rtp.Packet {
+	t.Helper()
+
+	enc, err := opus.NewEncoder(opus.Config{SampleRate: 48000, NumChannels: 1, FrameSize: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("create encoder: %s", err.Error())
+	}
+	packets, _, err := enc.Encode(make([]int16, n*960))
+	if err != nil {
+		t.Fatalf("encode: %s", err.Error())
+	}
+
+	var out []rtp.Packet
+	for i, packet := range packets {
+		out = append(out, rtp.Packet{
+			PayloadType:    111,
+			SequenceNumber: seq + uint16(i),
+			Timestamp:      ts + uint32(i*960),
+			SSRC:           ssrc,
+			Payload:        packet,
+		})
+	}
+	return out
+}
+
+// record writes packets in the given order and returns the file.
+func record(t *testing.T, cfg rtp.Config, packets []rtp.Packet, order []int) ([]byte, rtp.Stats) {
+	t.Helper()
+
+	var out bytes.Buffer
+	r, err := rtp.NewRecorder(&out, cfg)
+	if err != nil {
+		t.Fatalf("create recorder: %s", err.Error())
+	}
+	for _, i := range order {
+		if err := r.WritePacket(packets[i].Marshal()); err != nil {
+			t.Fatalf("write packet %d: %s", i, err.Error())
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %s", err.Error())
+	}
+	if err := r.WritePacket(packets[0].Marshal()); err != rtp.ErrClosed {
+		t.Fatalf("write after close should fail with %v, current %v", rtp.ErrClosed, err)
+	}
+
+	return out.Bytes(), r.Stats()
+}
+
+// check verifies the duration of a recorded file and that it decodes to
+// as many samples.
+func check(t *testing.T, file []byte, want time.Duration) {
+	t.Helper()
+
+	info, err := oggopus.Probe(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("probe: %s", err.Error())
+	}
+	if info.Duration != want {
+		t.Fatalf("duration should be equal %v, current %v", want, info.Duration)
+	}
+
+	rd, err := oggopus.NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("create reader: %s", err.Error())
+	}
+	decoded := 0
+	pcm := make([]int16, 5760)
+	for {
+		n, err := rd.Read(pcm)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read: %s", err.Error())
+		}
+		decoded += n
+	}
+	if samples := int(want / (time.Second / 48000)); decoded != samples {
+		t.Fatalf("decoded samples should be equal %d, current %d", samples, decoded)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	// Sequence numbers and timestamps wrap around.
+	packets := stream(t, 10, 65532, 4294967000, 1)
+	// The sender sent nothing for 100 ms before the last five packets.
+	for i := 5; i < 10; i++ {
+		packets[i].Timestamp += 5 * 960
+	}
+	other := packets[0]
+	other.PayloadType = 0
+	empty := packets[6]
+	empty.Payload = nil
+	packets = append(packets, other, empty)
+
+	file, stats := record(t, rtp.Config{PayloadType: 111}, packets, []int{0, 2, 1, 3, 5, 4, 4, 10, 11, 6, 8, 7, 9})
+
+	want := rtp.Stats{Packets: 10, Duplicate: 1, Ignored: 2}
+	if stats != want {
+		t.Fatalf("stats should be equal %+v, current %+v", want, stats)
+	}
+	check(t, file, 300*time.Millisecond)
+}
+
+func TestRecorderLoss(t *testing.T) {
+	packets := stream(t, 10, 100, 0, 1)
+	// 3 and 4 get lost, 3 arrives after it was given up.
+	order := []int{0, 1, 2, 5, 6, 7, 8, 3, 9}
+
+	for _, tc := range []struct {
+		loss  rtp.Loss
+		stats rtp.Stats
+	}{
+		{rtp.Gap, rtp.Stats{Packets: 8, Lost: 2, Late: 1}},
+		{rtp.PLC, rtp.Stats{Packets: 8, Lost: 2, Concealed: 2, Late: 1}},
+		{rtp.FEC, rtp.Stats{Packets: 8, Lost: 2, Recovered: 1, Concealed: 1, Late: 1}},
+	} {
+		cfg := rtp.Config{MaxHold: 60 * time.Millisecond, Loss: tc.loss}
+		file, stats := record(t, cfg, packets, order)
+		if stats != tc.stats {
+			t.Fatalf("stats should be equal %+v, current %+v", tc.stats, stats)
+		}
+		check(t, file, 200*time.Millisecond)
+	}
+}
+
+func TestRecorderAdvance(t *testing.T) {
+	packets := stream(t, 5, 100, 0, 1)
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+
+	var out bytes.Buffer
+	r, err := rtp.NewRecorder(&out, rtp.Config{MaxHold: 60 * time.Millisecond, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("create recorder: %s", err.Error())
+	}
+	write := func(i int) {
+		if err := r.WriteRTP(packets[i]); err != nil {
+			t.Fatalf("write packet %d: %s", i, err.Error())
+		}
+	}
+	for _, i := range []int{0, 2, 3} {
+		write(i)
+		now = now.Add(10 * time.Millisecond)
+	}
+
+	// The sender stalls: 1 is given up once 2 has waited for 60 ms.
+	for _, tc := range []struct {
+		at   time.Duration
+		want rtp.Stats
+	}{
+		{70 * time.Millisecond, rtp.Stats{Packets: 1}},
+		{71 * time.Millisecond, rtp.Stats{Packets: 3, Lost: 1}},
+	} {
+		if err := r.Advance(start.Add(tc.at)); err != nil {
+			t.Fatalf("advance: %s", err.Error())
+		}
+		if got := r.Stats(); got != tc.want {
+			t.Fatalf("stats after %v should be equal %+v, current %+v", tc.at, tc.want, got)
+		}
+	}
+
+	write(1)
+	write(4)
+	if err := r.Close(); err != nil {
+		t.Fatalf("close: %s", err.Error())
+	}
+	if err := r.Advance(now); err != rtp.ErrClosed {
+		t.Fatalf("advance after close should fail with %v, current %v", rtp.ErrClosed, err)
+	}
+
+	want := rtp.Stats{Packets: 4, Lost: 1, Late: 1}
+	if got := r.Stats(); got != want {
+		t.Fatalf("stats should be equal %+v, current %+v", want, got)
+	}
+	check(t, out.Bytes(), 100*time.Millisecond)
+}
+
+func TestRecorderConceal(t *testing.T) {
+	packets := stream(t, 20, 100, 0, 1)
+	// Two separate losses, each concealed by its own packets.
+	order := []int{0, 1, 2, 5, 6, 7, 8, 9, 10, 11, 13, 14, 15, 16, 17, 18, 19}
+
+	cfg := rtp.Config{MaxHold: 60 * time.Millisecond, Loss: rtp.PLC}
+	file, stats := record(t, cfg, packets, order)
+	want := rtp.Stats{Packets: 17, Lost: 3, Concealed: 3}
+	if stats != want {
+		t.Fatalf("stats should be equal %+v, current %+v", want, stats)
+	}
+	check(t, file, 400*time.Millisecond)
+
+	// Every packet, concealed or not, ends 20 ms after the one before.
+	d := ogg.NewDecoder(bytes.NewReader(file))
+	granule := int64(0)
+	for {
+		page, err := d.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decode page: %s", err.Error())
+		}
+		if page.Granule <= 0 || page.Type&ogg.EOS != 0 {
+			continue
+		}
+		granule += 960
+		if page.Granule != granule {
+			t.Fatalf("page granule should be equal %d, current %d", granule, page.Granule)
+		}
+	}
+	if granule != 20*960 {
+		t.Fatalf("last granule should be equal %d, current %d", 20*960, granule)
+	}
+}
+
+func TestRecorderSSRC(t *testing.T) {
+	packets := stream(t, 5, 1000, 123456, 1)
+	packets = append(packets, stream(t, 5, 40000, 999, 2)...)
+	// A late packet of the first source follows the second.
+	order := []int{0, 1, 2, 3, 4, 5, 6, 7, 2, 8, 9}
+
+	file, stats := record(t, rtp.Config{}, packets, order)
+
+	want := rtp.Stats{Packets: 10, Ignored: 1, SSRCChanges: 1}
+	if stats != want {
+		t.Fatalf("stats should be equal %+v, current %+v", want, stats)
+	}
+	check(t, file, 200*time.Millisecond)
+}
+
+func TestRecorderInterleavedSSRC(t *testing.T) {
+	// Both directions of a call, and a stray packet of a third source.
+	packets := stream(t, 10, 1000, 123456, 1)
+	packets = append(packets, stream(t, 10, 40000, 999, 2)...)
+	packets = append(packets, stream(t, 1, 7, 0, 3)...)
+	var order []int
+	for i := 0; i < 10; i++ {
+		order = append(order, i, 10+i)
+	}
+	order = append(order[:7], append([]int{20}, order[7:]...)...)
+
+	for _, tc := range []struct {
+		name  string
+		cfg   rtp.Config
+		stats rtp.Stats
+	}{
+		{"first source", rtp.Config{}, rtp.Stats{Packets: 10, Ignored: 11}},
+		{"selected source", rtp.Config{SSRC: 2}, rtp.Stats{Packets: 10, Ignored: 11}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file, stats := record(t, tc.cfg, packets, order)
+			if stats != tc.stats {
+				t.Fatalf("stats should be equal %+v, current %+v", tc.stats, stats)
+			}
+			check(t, file, 200*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/opus"
+	"github.com/paveldroo/go-ogg-packer/vad"
+)
+
+var ErrNoPause = errors.New("splitter needs a minimum pause")
+
+// SplitterConfig describes how a Splitter cuts speech into files.
+type SplitterConfig struct {
+	// Template is the file name pattern. "{index}" is replaced with the
+	// number of the utterance starting at 0. Existing files are never
+	// overwritten; a counter is added to a name that is taken, as with
+	// RotatorConfig.Template.
+	Template string
+	// Config is the format of the PCM input. Defaults to
+	// opus.NewDefaultConfig.
+	Config opus.Config
+	// MinPause is the silence that ends an utterance.
+	MinPause time.Duration
+	// MinLength keeps an utterance going over pauses until it is at least
+	// this long, and pads it with the silence that follows if it ends
+	// before.
+	MinLength time.Duration
+	// MaxLength starts a new file after this much audio even without a
+	// pause. The cut is placed exactly on the sample at the limit.
+	MaxLength time.Duration
+	// Padding is the silence kept before and after the speech of every
+	// utterance.
+	Padding time.Duration
+	// VAD configures the voice activity detector.
+	VAD []vad.Option
+	// OnUtterance is called after the file of an utterance is complete.
+	OnUtterance func(Utterance)
+}
+
+// Utterance is a file written by a Splitter.
+type Utterance struct {
+	File string
+	// Start and End locate the audio in the file, padding included, in
+	// the input of the Splitter.
+	Start time.Duration
+	End   time.Duration
+}
+
+// Splitter cuts a continuous PCM stream into one Ogg Opus file per
+// utterance, dropping the pauses in between except for some padding.
+// Every file is an independent stream with its own serial number that is
+// trimmed to exactly the samples it was given.
+type Splitter struct {
+	cfg      SplitterConfig
+	detector *vad.Detector
+	channels int
+	rate     int
+	minPause int // samples per channel
+	minLen   int
+	maxLen   int
+	padding  int
+
+	undecided []int16 // samples of the frame the detector has not decided
+	preroll   []int16 // the last padding of silence outside of utterances
+	pause     []int16 // silence since the last speech of the current utterance
+	pos       int64   // samples per channel of the input that have been decided
+
+	speaking   bool // an utterance is going on
+	packer     *Packer
+	sink       *FileSink
+	fname      string
+	start      int64 // samples per channel before the current file
+	written    int64 // samples per channel in the current file
+	serial     uint32
+	utterances []Utterance
+}
+
+func NewSplitter(cfg SplitterConfig) (*Splitter, error) {
+	if cfg.MinPause <= 0 {
+		return nil, ErrNoPause
+	}
+	if cfg.Config.SampleRate == 0 {
+		cfg.Config = opus.NewDefaultConfig()
+	}
+
+	samples := func(d time.Duration) int {
+		return int(d * time.Duration(cfg.Config.SampleRate) / time.Second)
+	}
+	maxLen := samples(cfg.MaxLength)
+	if maxLen <= 0 {
+		maxLen = -1
+	}
+
+	return &Splitter{
+		cfg:      cfg,
+		detector: vad.NewDetector(cfg.Config.SampleRate, cfg.Config.NumChannels, cfg.VAD...),
+		channels: cfg.Config.NumChannels,
+		rate:     cfg.Config.SampleRate,
+		minPause: samples(cfg.MinPause),
+		minLen:   samples(cfg.MinLength),
+		maxLen:   maxLen,
+		padding:  samples(cfg.Padding),
+	}, nil
+}
+
+func (s *Splitter) SendPCMChunk(chunk []int16) error {
+	s.undecided = append(s.undecided, chunk[:len(chunk)/s.channels*s.channels]...)
+	frame := s.detector.FrameSize() * s.channels
+	for _, speech := range s.detector.Write(chunk) {
+		if err := s.add(s.undecided[:frame], speech); err != nil {
+			return err
+		}
+		s.undecided = s.undecided[frame:]
+	}
+
+	return nil
+}
+
+// Close finishes the current utterance.
+func (s *Splitter) Close() error {
+	if len(s.undecided) > 0 {
+		// The last partial frame belongs to an utterance going on.
+		if err := s.add(s.undecided, s.speaking); err != nil {
+			return err
+		}
+		s.undecided = nil
+	}
+	if !s.speaking {
+		return nil
+	}
+	return s.endUtterance()
+}
+
+// Utterances returns all complete utterances.
+func (s *Splitter) Utterances() []Utterance {
+	return s.utterances
+}
+
+func (s *Splitter) add(samples []int16, speech bool) error {
+	s.pos += int64(len(samples) / s.channels)
+
+	switch {
+	case speech && !s.speaking:
+		s.speaking = true
+		s.start = s.pos - int64(len(samples)/s.channels+len(s.preroll)/s.channels)
+		s.written = 0
+		pcm := append(s.preroll, samples...)
+		s.preroll = s.preroll[:0]
+		return s.write(pcm)
+	case speech:
+		pcm := append(s.pause, samples...)
+		s.pause = s.pause[:0]
+		return s.write(pcm)
+	case !s.speaking:
+		s.addPreroll(samples)
+		return nil
+	}
+
+	s.pause = append(s.pause, samples...)
+	pause := len(s.pause) / s.channels
+	if pause >= s.minPause && s.written+int64(pause) >= int64(s.minLen) {
+		return s.endUtterance()
+	}
+	return nil
+}
+
+// write sends PCM to the current file, starting a new one whenever the
+// current one reaches the maximum length.
+func (s *Splitter) write(pcm []int16) error {
+	for len(pcm) > 0 {
+		if s.packer == nil {
+			if err := s.openFile(); err != nil {
+				return err
+			}
+		}
+
+		n := len(pcm)
+		if s.maxLen > 0 {
+			n = min(n, int(int64(s.maxLen)-s.written)*s.channels)
+		}
+
+		if err := s.packer.SendPCMChunk(pcm[:n]); err != nil {
+			return fmt.Errorf("send PCM chunk to %s: %w", s.fname, err)
+		}
+		s.written += int64(n / s.channels)
+		pcm = pcm[n:]
+
+		if s.maxLen > 0 && s.written >= int64(s.maxLen) {
+			if err := s.closeFile(); err != nil {
+				return err
+			}
+			s.start += s.written
+			s.written = 0
+		}
+	}
+
+	return nil
+}
+
+// endUtterance writes the padding after the speech, closes the file and
+// keeps the rest of the pause as the start of the next padding.
+func (s *Splitter) endUtterance() error {
+	if s.packer == nil {
+		// The file was closed at the maximum length right at the end of
+		// the speech. Padding alone makes no utterance.
+		s.speaking = false
+		s.addPreroll(s.pause)
+		s.pause = s.pause[:0]
+		return nil
+	}
+
+	n := min(len(s.pause), max(s.padding, s.minLen-int(s.written))*s.channels)
+	if err := s.write(s.pause[:n]); err != nil {
+		return err
+	}
+	rest := s.pause[n:]
+	s.pause = s.pause[:0]
+
+	// The file may have been closed at the maximum length already.
+	if s.packer != nil {
+		if err := s.closeFile(); err != nil {
+			return err
+		}
+	}
+	s.speaking = false
+	s.addPreroll(rest)
+
+	return nil
+}
+
+func (s *Splitter) addPreroll(samples []int16) {
+	s.preroll = append(s.preroll, samples...)
+	if extra := len(s.preroll) - s.padding*s.channels; extra > 0 {
+		s.preroll = append(s.preroll[:0], s.preroll[extra:]...)
+	}
+}
+
+func (s *Splitter) fileName() string {
+	return strings.ReplaceAll(s.cfg.Template, "{index}", strconv.Itoa(len(s.utterances)))
+}
+
+func (s *Splitter) openFile() error {
+	f, fname, err := createNew(s.fileName())
+	if err != nil {
+		return fmt.Errorf("create file sink: %w", err)
+	}
+	sink := newFileSink(f, 0)
+
+	opts := []Option{WithWriter(sink), WithConfig(s.cfg.Config), withExactLength()}
+	if s.serial != 0 {
+		opts = append(opts, withSerial(s.serial+1))
+	}
+	p, err := New(opts...)
+	if err != nil {
+		sink.Close()
+		return fmt.Errorf("create packer for %s: %w", fname, err)
+	}
+
+	s.packer = p
+	s.sink = sink
+	s.fname = fname
+	s.serial = p.oggPacker.Serial()
+
+	return nil
+}
+
+func (s *Splitter) closeFile() error {
+	fname := s.fname
+	if err := s.packer.Close(); err != nil {
+		s.sink.Close()
+		return fmt.Errorf("close packer for %s: %w", fname, err)
+	}
+	if err := s.sink.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", fname, err)
+	}
+
+	u := Utterance{
+		File:  fname,
+		Start: s.duration(s.start),
+		End:   s.duration(s.start + s.written),
+	}
+	s.utterances = append(s.utterances, u)
+	s.packer = nil
+	s.sink = nil
+	if s.cfg.OnUtterance != nil {
+		s.cfg.OnUtterance(u)
+	}
+
+	return nil
+}
+
+func (s *Splitter) duration(samples int64) time.Duration {
+	return time.Duration(samples) * time.Second / time.Duration(s.rate)
+}
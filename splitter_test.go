@@ -0,0 +1,185 @@
+package packer_test
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	extogg "mccoy.space/g/ogg"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+)
+
+func TestSplitter(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name      string
+		maxLength time.Duration
+		want      []packer.Utterance
+	}{
+		{
+			name: "by pauses",
+			want: []packer.Utterance{
+				{Start: 900 * ms, End: 2300 * ms},
+				{Start: 3900 * ms, End: 5300 * ms},
+			},
+		},
+		{
+			name:      "with maximum length",
+			maxLength: time.Second,
+			want: []packer.Utterance{
+				{Start: 900 * ms, End: 1900 * ms},
+				{Start: 1900 * ms, End: 2300 * ms},
+				{Start: 3900 * ms, End: 4900 * ms},
+				{Start: 4900 * ms, End: 5300 * ms},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var done []packer.Utterance
+			s, err := packer.NewSplitter(packer.SplitterConfig{
+				Template:    filepath.Join(dir, "utt-{index}.ogg"),
+				MinPause:    500 * ms,
+				MaxLength:   tt.maxLength,
+				Padding:     100 * ms,
+				OnUtterance: func(u packer.Utterance) { done = append(done, u) },
+			})
+			if err != nil {
+				t.Fatalf("create splitter: %s", err.Error())
+			}
+
+			pcm := voiceAndPauses()
+			for i := 0; i < len(pcm); i += 10000 {
+				if err := s.SendPCMChunk(pcm[i:min(i+10000, len(pcm))]); err != nil {
+					t.Fatalf("send PCM chunk: %s", err.Error())
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("close splitter: %s", err.Error())
+			}
+
+			utterances := s.Utterances()
+			if len(utterances) != len(tt.want) || len(done) != len(tt.want) {
+				t.Fatalf("utterances count should be equal %d, current %d", len(tt.want), len(utterances))
+			}
+			for i, u := range utterances {
+				want := tt.want[i]
+				if (u.Start-want.Start).Abs() > 40*ms || (u.End-want.End).Abs() > 40*ms {
+					t.Fatalf("utterance %d should be close to %v-%v, current %v-%v", i, want.Start, want.End, u.Start, u.End)
+				}
+
+				pages := oggPages(t, readFile(t, u.File))
+				if pages[len(pages)-1].Type&extogg.EOS == 0 {
+					t.Fatalf("%s should be a complete logical stream", u.File)
+				}
+				head := pages[0].Packets[0]
+				preSkip := int64(head[10]) | int64(head[11])<<8
+				samples := pages[len(pages)-1].Granule - preSkip
+				if want := int64((u.End - u.Start) * 48000 / time.Second); samples != want {
+					t.Fatalf("%s should hold %d samples, current %d", u.File, want, samples)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitterSameDirectory(t *testing.T) {
+	dir := t.TempDir()
+	cfg := packer.SplitterConfig{
+		Template: filepath.Join(dir, "utt-{index}.ogg"),
+		MinPause: 500 * time.Millisecond,
+		Padding:  100 * time.Millisecond,
+	}
+
+	var runs [][]packer.Utterance
+	var first [][]byte
+	for run := 0; run < 2; run++ {
+		utterances := split(t, cfg, voiceAndPauses())
+		if run == 0 {
+			for _, u := range utterances {
+				first = append(first, readFile(t, u.File))
+			}
+		}
+		runs = append(runs, utterances)
+	}
+
+	for i, want := range []string{"utt-0-1.ogg", "utt-1-1.ogg"} {
+		if got := filepath.Base(runs[1][i].File); got != want {
+			t.Fatalf("file name of the second run should be %s, current %s", want, got)
+		}
+	}
+	for i, u := range runs[0] {
+		if !bytes.Equal(readFile(t, u.File), first[i]) {
+			t.Fatalf("%s should not be overwritten", u.File)
+		}
+	}
+}
+
+func TestSplitterMaxLengthAtPause(t *testing.T) {
+	cfg := packer.SplitterConfig{
+		Template: filepath.Join(t.TempDir(), "utt-{index}.ogg"),
+		MinPause: 500 * time.Millisecond,
+		Padding:  100 * time.Millisecond,
+	}
+	pcm := voiceAndPauses()
+
+	// The first utterance is padding, speech and padding.
+	u := split(t, cfg, pcm)[0]
+	cfg.Template = filepath.Join(t.TempDir(), "utt-{index}.ogg")
+	cfg.MaxLength = u.End - u.Start - cfg.Padding
+
+	// The first file ends where the speech does.
+	utterances := split(t, cfg, pcm)
+	if got := utterances[0].End - utterances[0].Start; got != cfg.MaxLength {
+		t.Fatalf("first utterance should be %v long, current %v", cfg.MaxLength, got)
+	}
+	for i, u := range utterances {
+		if u.End-u.Start <= cfg.Padding {
+			t.Fatalf("utterance %d should hold more than padding, current %v-%v", i, u.Start, u.End)
+		}
+	}
+}
+
+// split runs pcm through a Splitter and returns its utterances.
+func split(t *testing.T, cfg packer.SplitterConfig, pcm []int16) []packer.Utterance {
+	t.Helper()
+
+	s, err := packer.NewSplitter(cfg)
+	if err != nil {
+		t.Fatalf("create splitter: %s", err.Error())
+	}
+	if err := s.SendPCMChunk(pcm); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close splitter: %s", err.Error())
+	}
+	return s.Utterances()
+}
+
+// voiceAndPauses returns 48 kHz mono PCM with 1 s of background noise,
+// 1 s of a voiced sound, 2 s of noise, 1 s of voice and 1 s of noise.
+func voiceAndPauses() []int16 {
+	rnd := rand.New(rand.NewSource(1))
+	var pcm []int16
+	for part, voiced := range []bool{false, true, false, false, true, false} {
+		for i := 0; i < 48000; i++ {
+			v := (rnd.Float64()*2 - 1) * 0.003
+			if voiced {
+				phase := 2 * math.Pi * 150 * float64(part*48000+i) / 48000
+				for h := 1; h <= 10; h++ {
+					v += 0.02 / float64(h) * math.Sin(float64(h)*phase)
+				}
+			}
+			pcm = append(pcm, int16(v*32767))
+		}
+	}
+	return pcm
+}
@@ -0,0 +1,276 @@
+// Package tags edits the OpusTags comment header of existing Ogg Opus files
+// without touching the audio.
+package tags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/paveldroo/go-ogg-packer/ogg"
+)
+
+var (
+	ErrNotOpus   = errors.New("no opus stream found")
+	ErrBadHeader = errors.New("comment header does not end on a page of its own")
+)
+
+// Rewrite copies the Ogg Opus file in src to dst with the comment header of
+// its first Opus stream changed by edit. The header is paginated again and
+// the sequence numbers of the following pages of the stream are adjusted,
+// with their checksums, if the number of header pages changes. All other
+// pages are copied unchanged.
+//
+// If the edited header fits into the space of the old one, padding is added
+// so that it takes exactly that space and the page layout stays the same.
+func Rewrite(src io.Reader, dst io.Writer, edit func(*ogg.Tags)) error {
+	write := func(page ogg.Page) error {
+		if _, err := dst.Write(page.Data); err != nil {
+			return fmt.Errorf("write page: %w", err)
+		}
+		return nil
+	}
+
+	d := ogg.NewDecoder(src)
+	h, err := readHeader(d, write)
+	if err != nil {
+		return err
+	}
+
+	edit(&h.tags)
+	pages, err := h.fittedPages(h.tags)
+	if err != nil {
+		pages, err = h.pages(h.tags)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(pages); err != nil {
+		return fmt.Errorf("write comment header: %w", err)
+	}
+
+	delta := uint32(ogg.CountPages(pages) - h.count)
+	ended := false
+	for {
+		page, err := d.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read page: %w", err)
+		}
+
+		if page.Serial == h.serial && !ended && delta != 0 {
+			ogg.SetSequence(page.Data, page.Sequence+delta)
+		}
+		if page.Serial == h.serial && page.Type&ogg.EOS != 0 {
+			// A later link of a chained file may reuse the serial.
+			ended = true
+		}
+		if err := write(page); err != nil {
+			return err
+		}
+	}
+}
+
+// RewriteFile changes the comment header of the first Opus stream in the
+// named file with edit. If the edited header fits into the pages of the old
+// one, they are overwritten in place. Otherwise the file is rewritten as by
+// Rewrite into a temporary file that then replaces it.
+func RewriteFile(name string, edit func(*ogg.Tags)) error {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	h, err := readHeader(ogg.NewDecoder(f), func(ogg.Page) error { return nil })
+	if err != nil {
+		return err
+	}
+	edit(&h.tags)
+
+	if h.contiguous {
+		if pages, err := h.fittedPages(h.tags); err == nil {
+			if _, err := f.WriteAt(pages, h.offset); err != nil {
+				return fmt.Errorf("write comment header: %w", err)
+			}
+			return f.Close()
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek: %w", err)
+	}
+	return replaceFile(name, f, func(src io.Reader, dst io.Writer) error {
+		return Rewrite(src, dst, func(t *ogg.Tags) { *t = h.tags })
+	})
+}
+
+// replaceFile writes the output of rewrite for f into a temporary file next
+// to it, which then takes its place.
+func replaceFile(name string, f *os.File, rewrite func(io.Reader, io.Writer) error) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := rewrite(f, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("set file mode: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("replace file: %w", err)
+	}
+
+	return nil
+}
+
+// commentHeader describes the pages holding the comment header of an Opus
+// stream.
+type commentHeader struct {
+	serial   uint32
+	tags     ogg.Tags
+	offset   int64  // offset of the first page
+	size     int64  // size of all pages
+	count    int    // number of pages
+	sequence uint32 // sequence number of the first page
+	// contiguous is set if no page of another stream lies between the
+	// pages of the header.
+	contiguous bool
+}
+
+// readHeader reads pages from d up to the end of the comment header of the
+// first Opus stream. Pages that are not part of the header are passed to
+// emit.
+func readHeader(d *ogg.Decoder, emit func(ogg.Page) error) (commentHeader, error) {
+	var h commentHeader
+	var packet []byte
+	found := false
+	off := int64(0)
+
+	for {
+		page, err := d.Decode()
+		if err == io.EOF {
+			if found {
+				return commentHeader{}, io.ErrUnexpectedEOF
+			}
+			return commentHeader{}, ErrNotOpus
+		}
+		if err != nil {
+			return commentHeader{}, fmt.Errorf("read page: %w", err)
+		}
+		start := off
+		off += int64(page.Size)
+
+		if !found {
+			if page.Type&ogg.BOS == 0 {
+				return commentHeader{}, ErrNotOpus
+			}
+			if len(page.Packets) > 0 && bytes.HasPrefix(page.Packets[0], []byte("OpusHead")) {
+				found = true
+				h.serial = page.Serial
+			}
+		}
+		if !found || page.Serial != h.serial || page.Type&ogg.BOS != 0 {
+			if err := emit(page); err != nil {
+				return commentHeader{}, err
+			}
+			continue
+		}
+
+		if h.count == 0 {
+			h.offset = start
+			h.sequence = page.Sequence
+			h.contiguous = true
+		} else if start != h.offset+h.size {
+			h.contiguous = false
+		}
+		h.count++
+		h.size += int64(page.Size)
+
+		if len(page.Packets) == 0 {
+			return commentHeader{}, ErrBadHeader
+		}
+		packet = append(packet, page.Packets[0]...)
+		if page.Partial && len(page.Packets) == 1 {
+			continue
+		}
+		if len(page.Packets) != 1 {
+			return commentHeader{}, ErrBadHeader
+		}
+
+		t, err := ogg.ParseTags(packet)
+		if err != nil {
+			return commentHeader{}, fmt.Errorf("parse comment header: %w", err)
+		}
+		h.tags = t
+
+		return h, nil
+	}
+}
+
+// pages paginates the comment header t with the sequence numbers of the old
+// header pages.
+func (h commentHeader) pages(t ogg.Tags) ([]byte, error) {
+	var b bytes.Buffer
+	if err := ogg.NewEncoder(h.serial, &b).Encode(0, [][]byte{t.Marshal()}); err != nil {
+		return nil, fmt.Errorf("encode comment header: %w", err)
+	}
+
+	pages := b.Bytes()
+	if err := ogg.RenumberPages(pages, h.sequence); err != nil {
+		return nil, fmt.Errorf("renumber comment header pages: %w", err)
+	}
+
+	return pages, nil
+}
+
+// fittedPages works like pages, but pads t so that the result takes exactly
+// as many pages and bytes as the old header. ogg.ErrHeaderOverflow is
+// returned if that is not possible.
+func (h commentHeader) fittedPages(t ogg.Tags) ([]byte, error) {
+	t.Padding = 0
+	size := len(t.Marshal())
+
+	for n := size; n <= int(h.size); n++ {
+		// A packet of n bytes takes n/255+1 lacing values, up to 255 per
+		// page.
+		segments := n/255 + 1
+		count := (segments + 254) / 255
+		if count == h.count && int64(27*count+segments+n) == h.size {
+			t.Padding = n - size
+			pages, err := h.pages(t)
+			if err != nil {
+				return nil, err
+			}
+			if int64(len(pages)) != h.size {
+				return nil, ogg.ErrHeaderOverflow
+			}
+			return pages, nil
+		}
+	}
+
+	return nil, ogg.ErrHeaderOverflow
+}
@@ -0,0 +1,202 @@
+package tags_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/ogg"
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+	"github.com/paveldroo/go-ogg-packer/tags"
+)
+
+const sourceFname = "../testdata/48k_1ch.pcm"
+
+func TestRewrite(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+
+	p, err := packer.New()
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	oggData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	tests := []struct {
+		name      string
+		value     string
+		wantPages int
+	}{
+		{
+			name:      "grow",
+			value:     "Morning call",
+			wantPages: 1,
+		},
+		{
+			name:      "spans pages",
+			value:     strings.Repeat("x", 100000),
+			wantPages: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := tags.Rewrite(bytes.NewReader(oggData), &out, func(t *ogg.Tags) {
+				t.Set("TITLE", tt.value)
+			})
+			if err != nil {
+				t.Fatalf("rewrite: %s", err.Error())
+			}
+
+			pages := checkPages(t, out.Bytes())
+			if n := headerPages(pages); n != tt.wantPages {
+				t.Fatalf("comment header pages count should be equal %d, current %d", tt.wantPages, n)
+			}
+
+			// The audio pages are unchanged apart from their sequence numbers.
+			orig := checkPages(t, oggData)
+			if len(pages) != len(orig)+tt.wantPages-1 {
+				t.Fatalf("pages count should be equal %d, current %d", len(orig)+tt.wantPages-1, len(pages))
+			}
+			for i, page := range orig[2:] {
+				got := pages[i+1+tt.wantPages]
+				if !bytes.Equal(got.Data[26:], page.Data[26:]) || got.Granule != page.Granule {
+					t.Fatalf("audio page %d differs", i)
+				}
+			}
+
+			r, err := oggopus.NewReader(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatalf("create reader: %s", err.Error())
+			}
+			if title := r.Tags().Get("TITLE"); len(title) != 1 || title[0] != tt.value {
+				t.Fatal("title should be set")
+			}
+		})
+	}
+}
+
+func TestRewriteFileInPlace(t *testing.T) {
+	sourcePCMData := pcmData(t, sourceFname)
+	fname := filepath.Join(t.TempDir(), "out.ogg")
+
+	// Files written to a FileSink reserve space in the comment header.
+	sink, err := packer.NewFileSink(fname, -1)
+	if err != nil {
+		t.Fatalf("create file sink: %s", err.Error())
+	}
+	p, err := packer.New(packer.WithWriter(sink))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(sourcePCMData); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("close packer: %s", err.Error())
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close file sink: %s", err.Error())
+	}
+
+	before := readFile(t, fname)
+	err = tags.RewriteFile(fname, func(t *ogg.Tags) {
+		t.Set("TITLE", "Morning call")
+		t.Add("SPEAKER", "operator")
+	})
+	if err != nil {
+		t.Fatalf("rewrite file: %s", err.Error())
+	}
+	after := readFile(t, fname)
+
+	if len(after) != len(before) {
+		t.Fatalf("file size should stay %d, current %d", len(before), len(after))
+	}
+	pages := checkPages(t, after)
+	headerSize := pages[0].Size + pages[1].Size
+	if !bytes.Equal(after[headerSize:], before[headerSize:]) {
+		t.Fatal("audio pages should be unchanged")
+	}
+
+	r, err := oggopus.NewReader(bytes.NewReader(after))
+	if err != nil {
+		t.Fatalf("create reader: %s", err.Error())
+	}
+	got := r.Tags()
+	if title := got.Get("TITLE"); len(title) != 1 || title[0] != "Morning call" {
+		t.Fatalf("title should be set, current %v", got.Comments)
+	}
+	if len(got.Get("DURATION")) != 1 {
+		t.Fatalf("other comments should be kept, current %v", got.Comments)
+	}
+}
+
+// checkPages decodes all pages of b, verifying their checksums and that
+// the sequence numbers of every stream have no gaps.
+func checkPages(t *testing.T, b []byte) []ogg.Page {
+	t.Helper()
+
+	var pages []ogg.Page
+	next := map[uint32]uint32{}
+	d := ogg.NewDecoder(bytes.NewReader(b))
+	for {
+		page, err := d.Decode()
+		if err == io.EOF {
+			return pages
+		}
+		if err != nil {
+			t.Fatalf("decode page: %s", err.Error())
+		}
+		if page.Sequence != next[page.Serial] {
+			t.Fatalf("page sequence number should be equal %d, current %d", next[page.Serial], page.Sequence)
+		}
+		next[page.Serial]++
+		pages = append(pages, page)
+	}
+}
+
+// headerPages returns the number of pages of the comment header.
+func headerPages(pages []ogg.Page) int {
+	n := 1
+	for pages[n].Partial {
+		n++
+	}
+	return n
+}
+
+func readFile(t *testing.T, fname string) []byte {
+	t.Helper()
+
+	d, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("read file: %s", err.Error())
+	}
+	return d
+}
+
+func pcmData(t *testing.T, fn string) []int16 {
+	t.Helper()
+
+	d, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("open pcm file: %s", err.Error())
+	}
+
+	result := make([]int16, len(d)/2)
+	if err := binary.Read(bytes.NewReader(d), binary.LittleEndian, result); err != nil {
+		t.Fatalf("binary read pcm file: %s", err.Error())
+	}
+
+	return result
+}
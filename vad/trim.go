@@ -0,0 +1,177 @@
+package vad
+
+import (
+	"math"
+	"time"
+)
+
+// unlimited stands for silence that is kept whatever its length.
+const unlimited = math.MaxInt / 4
+
+// TrimConfig selects the silence a Trimmer removes.
+type TrimConfig struct {
+	// Start removes the silence before the first speech.
+	Start bool
+	// End removes the silence after the last speech. Otherwise it is
+	// treated like a pause between speech.
+	End bool
+	// MaxPause shortens pauses between speech to this length by cutting
+	// out their middle. Zero keeps pauses.
+	MaxPause time.Duration
+	// Padding is the silence kept before the first and after the last
+	// speech when trimming them.
+	Padding time.Duration
+}
+
+// Trimmer removes silence from interleaved PCM as it passes through,
+// deciding what silence is with a Detector. Silence is held back until it
+// is known whether it is followed by speech, so the output lags the input
+// by at least a frame and by whole pauses when they may be trimmed.
+type Trimmer struct {
+	d        *Detector
+	channels int
+	padding  int // samples per channel
+	maxPause int
+
+	cfg       TrimConfig
+	undecided []int16 // samples of the frame the detector has not decided
+	pause     []int16 // the start and the end of the current pause
+	pauseLen  int     // samples per channel in the pause, including the cut ones
+	spoken    bool
+	removed   int
+
+	out []int16
+}
+
+// NewTrimmer creates a trimmer for PCM with the given sample rate and
+// number of channels. The options configure its Detector.
+func NewTrimmer(sampleRate, channels int, cfg TrimConfig, opts ...Option) *Trimmer {
+	samples := func(d time.Duration) int {
+		return int(d * time.Duration(sampleRate) / time.Second)
+	}
+
+	return &Trimmer{
+		d:        NewDetector(sampleRate, channels, opts...),
+		channels: channels,
+		padding:  samples(cfg.Padding),
+		maxPause: samples(cfg.MaxPause),
+		cfg:      cfg,
+	}
+}
+
+// Process adds interleaved samples and returns the samples that are kept
+// and no longer held back. The result is only valid until the next call.
+func (t *Trimmer) Process(pcm []int16) []int16 {
+	t.out = t.out[:0]
+
+	t.undecided = append(t.undecided, pcm[:len(pcm)/t.channels*t.channels]...)
+	frame := t.d.FrameSize() * t.channels
+	for _, speech := range t.d.Write(pcm) {
+		t.add(t.undecided[:frame], speech)
+		t.undecided = t.undecided[frame:]
+	}
+
+	return t.out
+}
+
+// Flush returns the remaining kept samples, with the final silence
+// trimmed as configured.
+func (t *Trimmer) Flush() []int16 {
+	t.out = t.out[:0]
+
+	if len(t.undecided) > 0 {
+		// The last partial frame continues the last decision.
+		t.add(t.undecided, t.d.speech)
+		t.undecided = nil
+	}
+	t.endPause(t.limits(true))
+
+	return t.out
+}
+
+// Removed returns the number of samples per channel removed so far.
+func (t *Trimmer) Removed() int {
+	return t.removed
+}
+
+// Segments returns the speech found so far, relative to the input.
+func (t *Trimmer) Segments() []Segment {
+	return t.d.Segments()
+}
+
+func (t *Trimmer) add(samples []int16, speech bool) {
+	if speech {
+		t.endPause(t.limits(false))
+		t.spoken = true
+		t.out = append(t.out, samples...)
+		return
+	}
+
+	if t.keepsPause() {
+		t.out = append(t.out, samples...)
+		return
+	}
+
+	t.pause = append(t.pause, samples...)
+	t.pauseLen += len(samples) / t.channels
+
+	// Cut out the middle of the pause once enough has piled up that can
+	// never be kept.
+	head, tail := t.holdLimits()
+	if head == unlimited || tail == unlimited {
+		return
+	}
+	if stored := len(t.pause) / t.channels; stored > head+2*tail+t.d.FrameSize() {
+		drop := stored - head - tail
+		t.pause = append(t.pause[:head*t.channels], t.pause[(head+drop)*t.channels:]...)
+	}
+}
+
+// limits returns how much of the start and of the end of the current
+// pause is kept when it ends with speech, or with the end of the input if
+// final is set.
+func (t *Trimmer) limits(final bool) (int, int) {
+	switch {
+	case !t.spoken && t.cfg.Start:
+		return 0, t.padding
+	case !t.spoken:
+		return unlimited, unlimited
+	case final && t.cfg.End:
+		return t.padding, 0
+	case t.maxPause > 0:
+		return t.maxPause - t.maxPause/2, t.maxPause / 2
+	default:
+		return unlimited, unlimited
+	}
+}
+
+// keepsPause reports whether the current pause is kept whole however it
+// ends, so it does not need to be held back.
+func (t *Trimmer) keepsPause() bool {
+	head, tail := t.limits(false)
+	finalHead, finalTail := t.limits(true)
+	return min(head, tail, finalHead, finalTail) == unlimited
+}
+
+// holdLimits returns how much of the start and of the end of the current
+// pause has to be stored for either way it may end.
+func (t *Trimmer) holdLimits() (int, int) {
+	head, tail := t.limits(false)
+	finalHead, finalTail := t.limits(true)
+	return max(head, finalHead), max(tail, finalTail)
+}
+
+// endPause writes the kept parts of the current pause to the output.
+func (t *Trimmer) endPause(head, tail int) {
+	stored := len(t.pause) / t.channels
+	if t.pauseLen <= head+tail {
+		t.out = append(t.out, t.pause...)
+	} else {
+		t.out = append(t.out, t.pause[:head*t.channels]...)
+		t.out = append(t.out, t.pause[(stored-tail)*t.channels:]...)
+		t.removed += t.pauseLen - head - tail
+	}
+
+	t.pause = t.pause[:0]
+	t.pauseLen = 0
+}
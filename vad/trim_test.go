@@ -0,0 +1,55 @@
+package vad_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/vad"
+)
+
+func TestTrimmer(t *testing.T) {
+	const rate = 16000
+	pcm := speechAndPauses(rate, 1)
+
+	tests := []struct {
+		name string
+		cfg  vad.TrimConfig
+		want time.Duration
+	}{
+		{
+			name: "keep everything",
+			want: 6 * time.Second,
+		},
+		{
+			name: "start and end",
+			cfg:  vad.TrimConfig{Start: true, End: true, Padding: 100 * time.Millisecond},
+			// 0.1 s + 1.2 s speech + 1.8 s pause + 1.2 s speech + 0.1 s,
+			// the speech including the hangover.
+			want: 4400 * time.Millisecond,
+		},
+		{
+			name: "pauses",
+			cfg:  vad.TrimConfig{Start: true, End: true, MaxPause: 500 * time.Millisecond, Padding: 100 * time.Millisecond},
+			want: 3100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := vad.NewTrimmer(rate, 1, tt.cfg)
+			var out []int16
+			for i := 0; i < len(pcm); i += 777 {
+				out = append(out, tr.Process(pcm[i:min(i+777, len(pcm))])...)
+			}
+			out = append(out, tr.Flush()...)
+
+			got := time.Duration(len(out)) * time.Second / rate
+			if absDuration(got-tt.want) > 40*time.Millisecond {
+				t.Fatalf("output should be %v long, current %v", tt.want, got)
+			}
+			if removed := len(pcm) - len(out); tr.Removed() != removed {
+				t.Fatalf("removed samples should be equal %d, current %d", removed, tr.Removed())
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Package vad detects speech in PCM and removes the silence around it.
+package vad
+
+import (
+	"math"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/internal/dsp"
+)
+
+const (
+	// FrameDuration is the length of the frames speech is decided for.
+	FrameDuration = 20 * time.Millisecond
+
+	floorWindow  = 150  // frames the noise floor is the minimum energy of
+	silenceFloor = -60  // dBFS below which nothing is speech
+	voicedMargin = 0.5  // share of the threshold enough for voiced frames
+	voicedFlat   = 0.35 // spectral flatness below which a frame is voiced
+	bandLow      = 250  // Hz, band of the flatness measure
+	bandHigh     = 4000 // Hz
+)
+
+// Segment is a stretch of speech, relative to the start of the input.
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Option configures a Detector.
+type Option func(*Detector)
+
+// WithThreshold sets how many dB above the noise floor a frame has to be
+// to count as speech. The default is 12 dB. Voiced frames, whose spectrum
+// has clear harmonics, need only half of it.
+func WithThreshold(db float64) Option {
+	return func(d *Detector) {
+		d.threshold = db
+	}
+}
+
+// WithHangover sets how long speech is assumed to go on after the last
+// frame that was detected as speech, so trailing consonants and short
+// gaps between words are not cut. The default is 200 ms.
+func WithHangover(hangover time.Duration) Option {
+	return func(d *Detector) {
+		d.hangover = int(hangover / FrameDuration)
+	}
+}
+
+// Detector decides for every 20 ms frame of interleaved PCM whether it
+// contains speech. It combines the frame energy relative to an adaptive
+// noise floor, the minimum energy of the last 3 s, with the spectral
+// flatness of the speech band, which tells voiced speech from noise.
+type Detector struct {
+	sampleRate int
+	channels   int
+	frame      int // samples per channel in a frame
+	threshold  float64
+	hangover   int
+
+	buf    []float64 // mono mix of the current frame
+	window []float64
+	fft    []complex128
+	floor  []floorEntry // increasing energies of the floor window
+
+	frames   int // frames decided
+	hang     int // frames of hangover left
+	speech   bool
+	start    int // first frame of the current segment
+	segments []Segment
+}
+
+type floorEntry struct {
+	frame  int
+	energy float64
+}
+
+// NewDetector creates a detector for PCM with the given sample rate and
+// number of channels.
+func NewDetector(sampleRate, channels int, opts ...Option) *Detector {
+	frame := sampleRate * int(FrameDuration/time.Millisecond) / 1000
+	d := &Detector{
+		sampleRate: sampleRate,
+		channels:   channels,
+		frame:      frame,
+		threshold:  12,
+		hangover:   int(200 * time.Millisecond / FrameDuration),
+		buf:        make([]float64, 0, frame),
+		window:     dsp.Hann(frame),
+		fft:        make([]complex128, dsp.NextPowerOfTwo(frame)),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// FrameSize returns the number of samples per channel of a frame.
+func (d *Detector) FrameSize() int {
+	return d.frame
+}
+
+// Write analyses interleaved samples and returns the decisions for the
+// frames they complete, in order. Samples of an incomplete frame are kept
+// for the next call.
+func (d *Detector) Write(pcm []int16) []bool {
+	var decisions []bool
+	for i := 0; i+d.channels <= len(pcm); i += d.channels {
+		sum := 0
+		for ch := 0; ch < d.channels; ch++ {
+			sum += int(pcm[i+ch])
+		}
+		d.buf = append(d.buf, float64(sum)/float64(d.channels)/32768)
+
+		if len(d.buf) == d.frame {
+			decisions = append(decisions, d.decide())
+			d.buf = d.buf[:0]
+		}
+	}
+
+	return decisions
+}
+
+// Segments returns the speech found so far. A segment that is still going
+// on ends at the last decided frame.
+func (d *Detector) Segments() []Segment {
+	segments := append([]Segment(nil), d.segments...)
+	if d.speech {
+		segments = append(segments, d.segment(d.start, d.frames))
+	}
+	return segments
+}
+
+func (d *Detector) decide() bool {
+	energy, flatness := d.features()
+	floor := d.updateFloor(energy)
+
+	active := energy > silenceFloor &&
+		(energy > floor+d.threshold || energy > floor+d.threshold*voicedMargin && flatness < voicedFlat)
+	switch {
+	case active:
+		d.hang = d.hangover
+	case d.hang > 0:
+		d.hang--
+		active = true
+	}
+
+	switch {
+	case active && !d.speech:
+		d.start = d.frames
+	case !active && d.speech:
+		d.segments = append(d.segments, d.segment(d.start, d.frames))
+	}
+	d.speech = active
+	d.frames++
+
+	return active
+}
+
+// features returns the energy of the current frame in dBFS and the
+// spectral flatness of its speech band, between 0 for a pure tone and 1
+// for white noise.
+func (d *Detector) features() (float64, float64) {
+	power := 0.0
+	for i, v := range d.buf {
+		power += v * v
+		d.fft[i] = complex(v*d.window[i], 0)
+	}
+	for i := len(d.buf); i < len(d.fft); i++ {
+		d.fft[i] = 0
+	}
+	energy := 10 * math.Log10(power/float64(len(d.buf))+1e-12)
+
+	dsp.FFT(d.fft)
+	binHz := float64(d.sampleRate) / float64(len(d.fft))
+	lo := max(int(bandLow/binHz), 1)
+	hi := min(int(bandHigh/binHz), len(d.fft)/2)
+	logSum, sum := 0.0, 0.0
+	for _, c := range d.fft[lo:hi] {
+		p := real(c)*real(c) + imag(c)*imag(c) + 1e-20
+		logSum += math.Log(p)
+		sum += p
+	}
+	n := float64(hi - lo)
+	flatness := math.Exp(logSum/n) / (sum / n)
+
+	return energy, flatness
+}
+
+// updateFloor adds the energy of a frame to the noise floor window and
+// returns the floor, the minimum of the window.
+func (d *Detector) updateFloor(energy float64) float64 {
+	for len(d.floor) > 0 && d.floor[len(d.floor)-1].energy >= energy {
+		d.floor = d.floor[:len(d.floor)-1]
+	}
+	d.floor = append(d.floor, floorEntry{frame: d.frames, energy: energy})
+	if d.floor[0].frame <= d.frames-floorWindow {
+		d.floor = d.floor[1:]
+	}
+	return d.floor[0].energy
+}
+
+func (d *Detector) segment(start, end int) Segment {
+	return Segment{Start: time.Duration(start) * FrameDuration, End: time.Duration(end) * FrameDuration}
+}
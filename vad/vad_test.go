@@ -0,0 +1,65 @@
+package vad_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/paveldroo/go-ogg-packer/vad"
+)
+
+func TestDetector(t *testing.T) {
+	for _, rate := range []int{16000, 48000} {
+		pcm := speechAndPauses(rate, 2)
+
+		d := vad.NewDetector(rate, 2)
+		for i := 0; i < len(pcm); i += 4000 {
+			d.Write(pcm[i:min(i+4000, len(pcm))])
+		}
+
+		want := []vad.Segment{
+			{Start: 1 * time.Second, End: 2200 * time.Millisecond},
+			{Start: 4 * time.Second, End: 5200 * time.Millisecond},
+		}
+		got := d.Segments()
+		if len(got) != len(want) {
+			t.Fatalf("segments count at %d Hz should be equal %d, current %d: %v", rate, len(want), len(got), got)
+		}
+		for i := range want {
+			if absDuration(got[i].Start-want[i].Start) > 40*time.Millisecond ||
+				absDuration(got[i].End-want[i].End) > 40*time.Millisecond {
+				t.Fatalf("segment %d at %d Hz should be close to %v, current %v", i, rate, want[i], got[i])
+			}
+		}
+	}
+}
+
+// speechAndPauses returns 1 s of background noise, 1 s of a voiced sound,
+// 2 s of noise, 1 s of voice and 1 s of noise.
+func speechAndPauses(sampleRate, channels int) []int16 {
+	rnd := rand.New(rand.NewSource(1))
+	var pcm []int16
+	for part, voiced := range []bool{false, true, false, false, true, false} {
+		for i := 0; i < sampleRate; i++ {
+			v := (rnd.Float64()*2 - 1) * 0.003
+			if voiced {
+				phase := 2 * math.Pi * 150 * float64(part*sampleRate+i) / float64(sampleRate)
+				for h := 1; h <= 10; h++ {
+					v += 0.02 / float64(h) * math.Sin(float64(h)*phase)
+				}
+			}
+			for ch := 0; ch < channels; ch++ {
+				pcm = append(pcm, int16(v*32767))
+			}
+		}
+	}
+	return pcm
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,154 @@
+// Package waveform summarizes audio as minimum and maximum sample values
+// per pixel for drawing waveforms, in the formats of the audiowaveform
+// tool that players such as peaks.js read.
+package waveform
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/paveldroo/go-ogg-packer/oggopus"
+)
+
+// version is the audiowaveform format version written, the first one with
+// more than one channel.
+const version = 2
+
+var ErrSamplesPerPixel = errors.New("waveform: samples per pixel must be positive")
+
+// Waveform holds the minimum and maximum of every SamplesPerPixel samples
+// per channel.
+type Waveform struct {
+	SampleRate      int
+	Channels        int
+	SamplesPerPixel int
+	// Data holds for every pixel a minimum and a maximum per channel.
+	Data []int16
+}
+
+// Length returns the number of pixels.
+func (w *Waveform) Length() int {
+	return len(w.Data) / (2 * w.Channels)
+}
+
+// MarshalJSON encodes the waveform in the JSON format of audiowaveform.
+func (w *Waveform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Version         int     `json:"version"`
+		Channels        int     `json:"channels"`
+		SampleRate      int     `json:"sample_rate"`
+		SamplesPerPixel int     `json:"samples_per_pixel"`
+		Bits            int     `json:"bits"`
+		Length          int     `json:"length"`
+		Data            []int16 `json:"data"`
+	}{version, w.Channels, w.SampleRate, w.SamplesPerPixel, 16, w.Length(), w.Data})
+}
+
+// WriteDat writes the waveform in the binary .dat format of audiowaveform
+// with 16-bit values.
+func (w *Waveform) WriteDat(wr io.Writer) error {
+	header := []int32{version, 0, int32(w.SampleRate), int32(w.SamplesPerPixel), int32(w.Length()), int32(w.Channels)}
+	if err := binary.Write(wr, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	if err := binary.Write(wr, binary.LittleEndian, w.Data); err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+	return nil
+}
+
+// Builder computes a waveform from PCM as it is written.
+type Builder struct {
+	w     Waveform
+	count int     // samples per channel of the current pixel
+	pixel []int16 // min and max per channel of the current pixel
+}
+
+// NewBuilder creates a Builder for interleaved PCM with the given sample
+// rate and channel count, with one pixel per samplesPerPixel samples.
+func NewBuilder(sampleRate, channels, samplesPerPixel int) (*Builder, error) {
+	if samplesPerPixel <= 0 {
+		return nil, ErrSamplesPerPixel
+	}
+
+	b := &Builder{
+		w: Waveform{
+			SampleRate:      sampleRate,
+			Channels:        channels,
+			SamplesPerPixel: samplesPerPixel,
+		},
+		pixel: make([]int16, 2*channels),
+	}
+	b.reset()
+
+	return b, nil
+}
+
+// Write adds interleaved samples.
+func (b *Builder) Write(pcm []int16) {
+	channels := b.w.Channels
+	for i := 0; i+channels <= len(pcm); i += channels {
+		for ch, v := range pcm[i : i+channels] {
+			b.pixel[2*ch] = min(b.pixel[2*ch], v)
+			b.pixel[2*ch+1] = max(b.pixel[2*ch+1], v)
+		}
+
+		b.count++
+		if b.count == b.w.SamplesPerPixel {
+			b.w.Data = append(b.w.Data, b.pixel...)
+			b.reset()
+		}
+	}
+}
+
+// Waveform returns the waveform of everything written so far, including a
+// last incomplete pixel. It shares no memory with the Builder.
+func (b *Builder) Waveform() *Waveform {
+	w := b.w
+	w.Data = append([]int16(nil), b.w.Data...)
+	if b.count > 0 {
+		w.Data = append(w.Data, b.pixel...)
+	}
+	return &w
+}
+
+func (b *Builder) reset() {
+	b.count = 0
+	for ch := 0; ch < b.w.Channels; ch++ {
+		b.pixel[2*ch] = math.MaxInt16
+		b.pixel[2*ch+1] = math.MinInt16
+	}
+}
+
+// FromOpus decodes the first Opus stream of an Ogg file and computes its
+// waveform at the 48 kHz rate of the decoder.
+func FromOpus(r io.Reader, samplesPerPixel int) (*Waveform, error) {
+	rd, err := oggopus.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read opus stream: %w", err)
+	}
+
+	channels := int(rd.Head().Channels)
+	b, err := NewBuilder(oggopus.SampleRate, channels, samplesPerPixel)
+	if err != nil {
+		return nil, err
+	}
+
+	pcm := make([]int16, 5760*channels) // 120 ms, the longest packet
+	for {
+		n, err := rd.Read(pcm)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode: %w", err)
+		}
+		b.Write(pcm[:n])
+	}
+
+	return b.Waveform(), nil
+}
@@ -0,0 +1,109 @@
+package waveform_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	packer "github.com/paveldroo/go-ogg-packer"
+	"github.com/paveldroo/go-ogg-packer/waveform"
+)
+
+func TestBuilder(t *testing.T) {
+	b, err := waveform.NewBuilder(8000, 2, 3)
+	if err != nil {
+		t.Fatalf("create builder: %s", err.Error())
+	}
+	b.Write([]int16{1, -1, 5, -2, -3, 7})
+	b.Write([]int16{2, 2, 4, 0})
+
+	w := b.Waveform()
+	want := []int16{-3, 5, -2, 7, 2, 4, 0, 2}
+	if !reflect.DeepEqual(w.Data, want) {
+		t.Fatalf("data should be equal %v, current %v", want, w.Data)
+	}
+	if w.Length() != 2 {
+		t.Fatalf("length should be equal 2, current %d", w.Length())
+	}
+
+	if _, err := waveform.NewBuilder(8000, 1, 0); err != waveform.ErrSamplesPerPixel {
+		t.Fatalf("error should be %v, current %v", waveform.ErrSamplesPerPixel, err)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	w := &waveform.Waveform{SampleRate: 48000, Channels: 1, SamplesPerPixel: 256, Data: []int16{-10, 20, -30, 40}}
+
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("marshal: %s", err.Error())
+	}
+	want := `{"version":2,"channels":1,"sample_rate":48000,"samples_per_pixel":256,"bits":16,"length":2,"data":[-10,20,-30,40]}`
+	if string(data) != want {
+		t.Fatalf("json should be equal %s, current %s", want, data)
+	}
+
+	var buf bytes.Buffer
+	if err := w.WriteDat(&buf); err != nil {
+		t.Fatalf("write dat: %s", err.Error())
+	}
+	var dat struct {
+		Version, Flags, SampleRate, SamplesPerPixel, Length, Channels int32
+		Data                                                          [4]int16
+	}
+	if err := binary.Read(&buf, binary.LittleEndian, &dat); err != nil {
+		t.Fatalf("read dat: %s", err.Error())
+	}
+	if dat.Version != 2 || dat.Flags != 0 || dat.SampleRate != 48000 || dat.SamplesPerPixel != 256 || dat.Length != 2 || dat.Channels != 1 {
+		t.Fatalf("unexpected dat header: %+v", dat)
+	}
+	if dat.Data != [4]int16{-10, 20, -30, 40} {
+		t.Fatalf("dat data should be equal %v, current %v", w.Data, dat.Data)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("dat should end after the data, %d bytes left", buf.Len())
+	}
+}
+
+func TestFromOpus(t *testing.T) {
+	d, err := os.ReadFile("../testdata/48k_1ch.pcm")
+	if err != nil {
+		t.Fatalf("open pcm file: %s", err.Error())
+	}
+	pcm := make([]int16, len(d)/2)
+	if err := binary.Read(bytes.NewReader(d), binary.LittleEndian, pcm); err != nil {
+		t.Fatalf("binary read pcm file: %s", err.Error())
+	}
+
+	p, err := packer.New(packer.WithWaveform(480))
+	if err != nil {
+		t.Fatalf("create new packer: %s", err.Error())
+	}
+	if err := p.SendPCMChunk(pcm); err != nil {
+		t.Fatalf("send PCM chunk: %s", err.Error())
+	}
+	oggData, err := p.GetResult()
+	if err != nil {
+		t.Fatalf("get result from packer: %s", err.Error())
+	}
+
+	b, _ := waveform.NewBuilder(48000, 1, 480)
+	b.Write(pcm)
+	if got, want := p.Waveform(), b.Waveform(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("packer waveform should be equal the waveform of the input")
+	}
+
+	w, err := waveform.FromOpus(bytes.NewReader(oggData), 480)
+	if err != nil {
+		t.Fatalf("waveform from opus: %s", err.Error())
+	}
+	if want := (len(pcm) + 479) / 480; w.Length() != want {
+		t.Fatalf("length should be equal %d, current %d", want, w.Length())
+	}
+	if w.SampleRate != 48000 || w.Channels != 1 {
+		t.Fatalf("format should be 48000 Hz 1 channel, current %d Hz %d channels", w.SampleRate, w.Channels)
+	}
+}